@@ -0,0 +1,200 @@
+package solve
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Agent performs a real-time search (LRTA*) for agents that need to decide on the next move within a bounded
+// amount of time. For each decision a bounded local search with A* is performed from the current state, after which
+// the heuristic of the states in the local search space is updated from the states at its frontier, and the agent
+// moves towards the most promising state on the frontier.
+//
+// The updated heuristic is kept in a table, so repeated trials from the same state converge to the optimal solution
+// if the heuristic of the states is admissible.
+type Agent struct {
+	key       func(State) interface{}
+	lookahead int
+	context   Context
+	learned   map[interface{}]float64
+}
+
+// NewAgent creates an agent that identifies the states by key, which must return valid map keys that are equal for
+// equal states. The lookahead is the maximum number of states that are expanded for each decision, at least 1.
+// The context is passed to the methods of the states. In State.Heuristic the path costs and the depth of the
+// context are relative to the current state of the agent, and the bound is infinite.
+func NewAgent(key func(State) interface{}, lookahead int, context interface{}) *Agent {
+	if lookahead < 1 {
+		lookahead = 1
+	}
+	return &Agent{key, lookahead, Context{context, &evaluation{}, nil}, make(map[interface{}]float64)}
+}
+
+// Heuristic returns the learned heuristic of the state, or the heuristic of the state itself if nothing is learned
+// for the state yet
+func (a *Agent) Heuristic(state State) float64 {
+	return a.heuristic(state, 0, 0)
+}
+
+// Returns the learned heuristic of the state, or the heuristic of the state itself with the costs and depth from the
+// current state of the agent in the context. The bound is infinite since the local search has no contour.
+func (a *Agent) heuristic(state State, cost float64, depth int) float64 {
+	if h, ok := a.learned[a.key(state)]; ok {
+		return h
+	}
+	return a.context.evaluate(state, cost, depth, math.Inf(1))
+}
+
+// Returns the costs of the step from the parent to the child, where cost is the step cost provided by eachChild
+func costOfStep(parent State, child State, cost float64, context Context) float64 {
+	if math.IsNaN(cost) {
+		return child.Cost(context) - parent.Cost(context)
+	}
+	return cost
+}
+
+type predecessor struct {
+	key  interface{}
+	cost float64
+}
+
+// Next returns the next state to move to from the current state and the costs of that move, and updates the learned
+// heuristic. Returns false if the current state is a goal, or if no goal can be reached from it.
+func (a *Agent) Next(current State) (State, float64, bool) {
+	if current.IsGoal(a.context) {
+		return nil, 0, false
+	}
+	// local search with A*, on the costs from the current state and the learned heuristic
+	queue := aStar(FIFO().(tieBreaker))
+	heuristics := make(map[*node]float64) // the heuristic of the queued nodes, reused for the frontier
+	h := a.heuristic(current, 0, 0)
+	root := &node{nil, current, 0, h, 0, nil, 0}
+	heuristics[root] = h
+	queue.Add(root)
+	closed := make(map[interface{}]bool)
+	predecessors := make(map[interface{}][]predecessor)
+	var goal *node
+	for expanded := 0; expanded < a.lookahead; {
+		n := queue.Take()
+		if n == nil {
+			break
+		}
+		k := a.key(n.state)
+		if closed[k] {
+			continue
+		}
+		if n.state.IsGoal(a.context) {
+			goal = n
+			break
+		}
+		closed[k] = true
+		expanded++
+		eachChild(n.state, a.context, func(child State, cost float64) bool {
+			step := costOfStep(n.state, child, cost, a.context)
+			childKey := a.key(child)
+			predecessors[childKey] = append(predecessors[childKey], predecessor{k, step})
+			if !closed[childKey] {
+				g := n.cost + step
+				h := a.heuristic(child, g, n.depth+1)
+				childNode := &node{n, child, g, g + h, n.depth + 1, nil, 0}
+				heuristics[childNode] = h
+				queue.Add(childNode)
+			}
+			return true
+		})
+	}
+
+	// the frontier of the local search space
+	var frontier []*node
+	if goal != nil {
+		frontier = append(frontier, goal)
+	}
+	for n := queue.Take(); n != nil; n = queue.Take() {
+		if !closed[a.key(n.state)] {
+			frontier = append(frontier, n)
+		}
+	}
+	a.update(closed, predecessors, frontier, heuristics)
+
+	var best *node
+	for _, n := range frontier {
+		if best == nil || n.value < best.value {
+			best = n
+		}
+	}
+	if best == nil || math.IsInf(best.value, 1) {
+		return nil, 0, false
+	}
+	for best.parent.parent != nil {
+		best = best.parent
+	}
+	return best.state, best.cost, true
+}
+
+// Updates the heuristic of the closed states to the cheapest costs to reach a state on the frontier plus the
+// heuristic of that state as computed when it was queued, with a Dijkstra search backwards from the frontier
+func (a *Agent) update(closed map[interface{}]bool, predecessors map[interface{}][]predecessor, frontier []*node, heuristics map[*node]float64) {
+	h := make(map[interface{}]float64, len(closed))
+	for k := range closed {
+		h[k] = math.Inf(1)
+	}
+	var queue keyQueue
+	for _, n := range frontier {
+		heap.Push(&queue, keyEntry{a.key(n.state), heuristics[n]})
+	}
+	for queue.Len() > 0 {
+		e := heap.Pop(&queue).(keyEntry)
+		for _, p := range predecessors[e.key] {
+			if current, ok := h[p.key]; ok && p.cost+e.value < current {
+				h[p.key] = p.cost + e.value
+				heap.Push(&queue, keyEntry{p.key, p.cost + e.value})
+			}
+		}
+	}
+	for k, value := range h {
+		if old, ok := a.learned[k]; !ok || value > old {
+			a.learned[k] = value
+		}
+	}
+}
+
+// Trial moves from the root state until a goal is reached, for at most maxSteps steps. The result contains the
+// states that are visited by the agent, which may contain the same state multiple times, and the costs of the
+// moves. Solution is empty if no goal is reached.
+func (a *Agent) Trial(root State, maxSteps int) Result {
+	path := []State{root}
+	cost := 0.0
+	current := root
+	for i := 0; i < maxSteps && !current.IsGoal(a.context); i++ {
+		next, step, ok := a.Next(current)
+		if !ok {
+			return Result{}
+		}
+		cost += step
+		path = append(path, next)
+		current = next
+	}
+	if !current.IsGoal(a.context) {
+		return Result{}
+	}
+	return Result{Solution: path, Cost: cost}
+}
+
+type keyEntry struct {
+	key   interface{}
+	value float64
+}
+
+type keyQueue []keyEntry
+
+func (q keyQueue) Len() int            { return len(q) }
+func (q keyQueue) Less(i, j int) bool  { return q[i].value < q[j].value }
+func (q keyQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *keyQueue) Push(x interface{}) { *q = append(*q, x.(keyEntry)) }
+
+func (q *keyQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
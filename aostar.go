@@ -0,0 +1,188 @@
+package solve
+
+import (
+	"math"
+)
+
+// AndOrState is a state of a problem that decomposes into subproblems, to be solved with SolveAndOr. The state is
+// solved by one of its decompositions (OR), and a decomposition is solved when all its subproblems are solved (AND).
+//
+// A decomposition without subproblems solves the state directly, so a state with such a decomposition is a goal. A
+// state without decompositions can not be solved. The decompositions may not contain cycles.
+type AndOrState interface {
+	// The alternative decompositions of this state
+	Decompose(ctx Context) []Decomposition
+
+	// Estimated costs to solve this state. The solution is optimal if the heuristic is admissible.
+	Heuristic(ctx Context) float64
+}
+
+// Decomposition of a state into subproblems that must all be solved
+type Decomposition struct {
+	// The costs of the decomposition itself, which are added to the aggregated costs of the subproblems
+	Cost        float64
+	Subproblems []AndOrState
+}
+
+// Aggregation defines how the costs of the subproblems of a decomposition are combined
+type Aggregation int
+
+const (
+	// SumCosts adds the costs of the subproblems, like the total effort to solve them one after another
+	SumCosts Aggregation = iota
+
+	// MaxCosts takes the maximum costs of the subproblems, like the duration when they are solved in parallel
+	MaxCosts Aggregation = iota
+)
+
+func (a Aggregation) aggregate(costs []float64) float64 {
+	total := 0.0
+	for _, c := range costs {
+		if a == MaxCosts {
+			total = math.Max(total, c)
+		} else {
+			total += c
+		}
+	}
+	return total
+}
+
+// SolutionTree is the solution of a state, with the solutions of the subproblems of the chosen decomposition. The
+// same subtree can be shared by different parents when subproblems are shared.
+type SolutionTree struct {
+	State    AndOrState
+	Cost     float64 // the costs to solve the state
+	Subtrees []*SolutionTree
+}
+
+// AndOrResult is the result of SolveAndOr. Solution is nil if the root can not be solved.
+type AndOrResult struct {
+	Solution *SolutionTree
+	Cost     float64
+	Expanded int
+}
+
+type aoConnector struct {
+	cost     float64
+	children []*aoNode
+}
+
+type aoNode struct {
+	state      AndOrState
+	cost       float64 // the estimated costs, exact when the node is solved
+	solved     bool
+	expanded   bool
+	connectors []aoConnector
+	best       int // index of the best connector, -1 if none
+	parents    []*aoNode
+}
+
+type aoStar struct {
+	key         func(AndOrState) interface{}
+	aggregation Aggregation
+	context     Context
+	nodes       map[interface{}]*aoNode
+	expanded    int
+}
+
+// SolveAndOr finds the cheapest solution tree for the root state with AO*, where the costs of a decomposition are
+// its own costs plus the aggregated costs of its subproblems. The states are identified by key, which must return
+// valid map keys that are equal for equal states, so subproblems that occur multiple times are solved only once. The
+// context is passed to the methods of the states.
+//
+// The heuristic of a subproblem is computed once and shared by all decompositions that contain it, so heuristics
+// that depend on the path costs or the depth in the context, like SwitchAtDepth, are not supported. The bound in the
+// context is infinite.
+func SolveAndOr(root AndOrState, key func(AndOrState) interface{}, aggregation Aggregation, context interface{}) AndOrResult {
+	ao := &aoStar{key, aggregation, Context{context, nil, nil}, make(map[interface{}]*aoNode), 0}
+	r := ao.node(root)
+	for !r.solved && !math.IsInf(r.cost, 1) {
+		tip := ao.tip(r, make(map[*aoNode]bool))
+		ao.expand(tip)
+		ao.revise(tip)
+	}
+	if !r.solved {
+		return AndOrResult{Expanded: ao.expanded}
+	}
+	return AndOrResult{ao.tree(r, make(map[*aoNode]*SolutionTree)), r.cost, ao.expanded}
+}
+
+// Returns the node of the state, which is created if it doesn't exist yet
+func (ao *aoStar) node(state AndOrState) *aoNode {
+	k := ao.key(state)
+	if n, ok := ao.nodes[k]; ok {
+		return n
+	}
+	n := &aoNode{state, state.Heuristic(ao.context), false, false, nil, -1, nil}
+	ao.nodes[k] = n
+	return n
+}
+
+// Returns an unexpanded node in the best partial solution below n, which is not solved
+func (ao *aoStar) tip(n *aoNode, seen map[*aoNode]bool) *aoNode {
+	if !n.expanded {
+		return n
+	}
+	seen[n] = true
+	for _, child := range n.connectors[n.best].children {
+		if child.solved || seen[child] {
+			continue
+		}
+		if tip := ao.tip(child, seen); tip != nil {
+			return tip
+		}
+	}
+	return nil
+}
+
+func (ao *aoStar) expand(n *aoNode) {
+	n.expanded = true
+	ao.expanded++
+	for _, d := range n.state.Decompose(ao.context) {
+		c := aoConnector{d.Cost, nil}
+		for _, sub := range d.Subproblems {
+			child := ao.node(sub)
+			child.parents = append(child.parents, n)
+			c.children = append(c.children, child)
+		}
+		n.connectors = append(n.connectors, c)
+	}
+}
+
+// Recomputes the costs and the best connector of the node, and of its ancestors for which they change
+func (ao *aoStar) revise(n *aoNode) {
+	pending := []*aoNode{n}
+	for len(pending) > 0 {
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		cost, solved, best := math.Inf(1), false, -1
+		for i, c := range n.connectors {
+			costs := make([]float64, len(c.children))
+			all := true
+			for j, child := range c.children {
+				costs[j] = child.cost
+				all = all && child.solved
+			}
+			if value := c.cost + ao.aggregation.aggregate(costs); value < cost || value == cost && all && !solved {
+				cost, solved, best = value, all, i
+			}
+		}
+		if cost == n.cost && solved == n.solved && best == n.best {
+			continue
+		}
+		n.cost, n.solved, n.best = cost, solved, best
+		pending = append(pending, n.parents...)
+	}
+}
+
+func (ao *aoStar) tree(n *aoNode, trees map[*aoNode]*SolutionTree) *SolutionTree {
+	if t, ok := trees[n]; ok {
+		return t
+	}
+	t := &SolutionTree{n.state, n.cost, nil}
+	for _, child := range n.connectors[n.best].children {
+		t.Subtrees = append(t.Subtrees, ao.tree(child, trees))
+	}
+	trees[n] = t
+	return t
+}
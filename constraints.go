@@ -1,6 +1,9 @@
 package solve
 
-import "strconv"
+import (
+	"math"
+	"strconv"
+)
 
 // Constraint is a marker interface for constraints. Because the constraint methods refer to internal data structures
 // we can not expose those methods
@@ -13,6 +16,30 @@ type iconstraint interface {
 	reset()
 }
 
+// A constraint that learns from the values of the children of each expanded node, except for goal nodes. The
+// minimum value of the children is provided.
+type learningConstraint interface {
+	onExpanded(node *node, min float64)
+}
+
+// A constraint that may wrongly drop states
+type probabilisticConstraint interface {
+	falsePositiveRate() float64
+}
+
+// A constraint that detects duplicate states, which can be applied on the canonical states instead
+type canonicalizable interface {
+	canonicalized(canonical func(State) State) iconstraint
+}
+
+// Returns the canonical state of the state, or the state itself if no canonical function is provided
+func canonicalState(canonical func(State) State, state State) State {
+	if canonical == nil {
+		return state
+	}
+	return canonical(state)
+}
+
 // value is irrelevant
 type noConstraint bool
 
@@ -31,8 +58,9 @@ func (c noConstraint) String() string {
 }
 
 type noLoopConstraint struct {
-	samefn func(State, State) bool
-	depth  int
+	samefn    func(State, State) bool
+	depth     int
+	canonical func(State) State
 }
 
 func (c noLoopConstraint) onVisit(node *node) bool {
@@ -41,11 +69,12 @@ func (c noLoopConstraint) onVisit(node *node) bool {
 
 func (c noLoopConstraint) onExpand(node *node) bool {
 	ancestor := node.parent
+	state := canonicalState(c.canonical, node.state)
 	for i := 0; i < c.depth; i++ {
 		if ancestor == nil {
 			return false
 		}
-		if c.samefn(node.state, ancestor.state) {
+		if c.samefn(state, canonicalState(c.canonical, ancestor.state)) {
 			return true
 		}
 		ancestor = ancestor.parent
@@ -55,6 +84,11 @@ func (c noLoopConstraint) onExpand(node *node) bool {
 
 func (c noLoopConstraint) reset() {}
 
+func (c noLoopConstraint) canonicalized(canonical func(State) State) iconstraint {
+	c.canonical = canonical
+	return c
+}
+
 func (c noLoopConstraint) String() string {
 	return "NoLoopConstraint(" + strconv.Itoa(c.depth) + ")"
 }
@@ -64,12 +98,11 @@ func (c noLoopConstraint) String() string {
 // A depth of 1 will compare only with the parent state. A depth of 2 will compare with
 // the parent state and its parent state, etc.
 //
-// The states are compared using the provided function. Note that symmetric states may
-// be considered equal by this function to eliminate symmetric branches from the search tree.
+// The (canonical) states are compared using the provided function.
 //
-// Performance is linear in the depth or the actual search depth, whichever is smaller
+// Performance is linear in the depth or the actual search depth, whichever is smaller.
 func NoLoopConstraint(depth int, samefn func(State, State) bool) Constraint {
-	return noLoopConstraint{samefn, depth}
+	return noLoopConstraint{samefn, depth, nil}
 }
 
 // NoConstraint returns a constraint that will not drop states from the search tree
@@ -81,20 +114,20 @@ func NoConstraint() Constraint {
 //
 // A typical implementation will look something like:
 //
-//  type cpMap map[int64]float64
+//	type cpMap map[int64]float64
 //
-//  func (c cpMap) Get(state solve.State) (float64, bool) {
-//  	value, ok := c[state.(swapState).id]
-//  	return value, ok
-//  }
+//	func (c cpMap) Get(state solve.State) (float64, bool) {
+//		value, ok := c[state.(swapState).id]
+//		return value, ok
+//	}
 //
-//  func (c cpMap) Put(state solve.State, value float64) {
-//  	c[state.(swapState).id] = value
-//  }
+//	func (c cpMap) Put(state solve.State, value float64) {
+//		c[state.(swapState).id] = value
+//	}
 //
-//  func (c *cpMap) Clear() {
-//  	*c = make(cpMap)
-//  }
+//	func (c *cpMap) Clear() {
+//		*c = make(cpMap)
+//	}
 type CPMap interface {
 	Get(state State) (float64, bool)
 	Put(state State, value float64)
@@ -102,27 +135,35 @@ type CPMap interface {
 }
 
 type cheapestPathConstraint struct {
-	m CPMap
+	m         CPMap
+	canonical func(State) State
 }
 
 func (c cheapestPathConstraint) onExpand(node *node) bool {
-	current, ok := c.m.Get(node.state)
-	if !ok || node.value < current {
-		c.m.Put(node.state, node.value)
+	state := canonicalState(c.canonical, node.state)
+	current, ok := c.m.Get(state)
+	if !ok || node.cost < current {
+		c.m.Put(state, node.cost)
 		return false
 	}
 	return true
 }
 
 func (c cheapestPathConstraint) onVisit(node *node) bool {
-	current, ok := c.m.Get(node.state)
-	if !ok || node.value <= current {
-		c.m.Put(node.state, node.value)
+	state := canonicalState(c.canonical, node.state)
+	current, ok := c.m.Get(state)
+	if !ok || node.cost <= current {
+		c.m.Put(state, node.cost)
 		return false
 	}
 	return true
 }
 
+func (c cheapestPathConstraint) canonicalized(canonical func(State) State) iconstraint {
+	c.canonical = canonical
+	return c
+}
+
 func (c cheapestPathConstraint) reset() {
 	c.m.Clear()
 }
@@ -132,13 +173,234 @@ func (c cheapestPathConstraint) String() string {
 }
 
 // CheapestPathConstraint will drop a state when a cheaper path was found to an equal state. If two equal states have the
-// same cost, than any of those states will be dropped.
+// same cost, than any of those states will be dropped. The costs of the paths are compared, since the heuristic of a
+// state is only computed when it is not dropped.
 //
-// A custom map implementation needs to be provided to efficiently store the state. Note that symmetric states may map
-// to the same key to eliminate symmetric branches from the search tree.
+// A custom map implementation needs to be provided to efficiently store the (canonical) states.
 //
 // Performance is constant time, but memory usage is linear to the number of states. Therefore this constraint
 // is most usable in combination with A* or Breadth-First.
 func CheapestPathConstraint(m CPMap) Constraint {
-	return cheapestPathConstraint{m}
+	return cheapestPathConstraint{m, nil}
+}
+
+// Replacement is the policy used by the TranspositionTableConstraint to decide if a new entry replaces the existing
+// entry in a slot of the table
+type Replacement int
+
+const (
+	// ReplaceByDepth replaces the existing entry if the new state is not deeper in the search tree. States near the
+	// root are kept in the table since they prune the largest subtrees.
+	ReplaceByDepth Replacement = iota
+
+	// ReplaceByAge always replaces the existing entry, so the most recently seen states are kept in the table
+	ReplaceByAge Replacement = iota
+)
+
+type ttEntry struct {
+	used      bool
+	hash      uint64
+	cost      float64 // best costs seen
+	heuristic float64 // backed-up heuristic
+	depth     int
+	iteration int
+}
+
+type transpositionTable struct {
+	entries     []ttEntry
+	hash        func(State) uint64
+	replacement Replacement
+	iteration   *int
+	canonical   func(State) State
+}
+
+func (c transpositionTable) lookup(state State) (*ttEntry, uint64) {
+	hash := c.hash(canonicalState(c.canonical, state))
+	return &c.entries[hash%uint64(len(c.entries))], hash
+}
+
+func (c transpositionTable) onExpand(node *node) bool {
+	entry, hash := c.lookup(node.state)
+	iteration := *c.iteration
+	if !entry.used || entry.hash != hash {
+		if !entry.used || c.replacement == ReplaceByAge || node.depth <= entry.depth {
+			*entry = ttEntry{true, hash, node.cost, 0, node.depth, iteration}
+		}
+		return false
+	}
+	node.value = math.Max(node.value, node.cost+entry.heuristic)
+	if node.cost > entry.cost || node.cost == entry.cost && entry.iteration == iteration {
+		// a path that is at least as cheap is already searched
+		return true
+	}
+	entry.cost = node.cost
+	entry.depth = node.depth
+	entry.iteration = iteration
+	return false
+}
+
+func (c transpositionTable) onVisit(node *node) bool {
+	return false
+}
+
+func (c transpositionTable) onExpanded(node *node, min float64) {
+	entry, hash := c.lookup(node.state)
+	if entry.used && entry.hash == hash {
+		entry.heuristic = math.Max(entry.heuristic, min-node.cost)
+	}
+}
+
+func (c transpositionTable) canonicalized(canonical func(State) State) iconstraint {
+	c.canonical = canonical
+	return c
+}
+
+// The table is kept between iterations, a new iteration only makes the entries older
+func (c transpositionTable) reset() {
+	*c.iteration++
+}
+
+func (c transpositionTable) String() string {
+	return "TranspositionTableConstraint(" + strconv.Itoa(len(c.entries)) + ")"
+}
+
+// TranspositionTableConstraint will drop a state when the same state is reached by a path that is at least as cheap,
+// using a table with a fixed number of entries, the size, which must be at least 1. This bounds the memory usage, at
+// the price that not all duplicate states are detected.
+//
+// The table is intended for IDA*, since it persists between the iterations. Besides the cheapest costs of the states
+// it stores a heuristic that is learned from the children of the states, which increases the value of states that
+// are reached again in a next iteration. The learned heuristic is only valid if the heuristic of the states is
+// admissible.
+//
+// The (canonical) states are identified by the provided hash function. Different states with the same hash are
+// considered equal, so a good 64-bit hash function should be used.
+func TranspositionTableConstraint(size int, hash func(State) uint64, replacement Replacement) Constraint {
+	if size <= 0 {
+		panic("TranspositionTableConstraint requires a positive size")
+	}
+	return transpositionTable{make([]ttEntry, size), hash, replacement, new(int), nil}
+}
+
+type bloomFilter struct {
+	bits      []uint64
+	size      uint64 // number of bits
+	hashes    int
+	hash      func(State) uint64
+	count     *int // number of states added
+	canonical func(State) State
+}
+
+// Returns true if the state was (probably) already added
+func (c bloomFilter) add(state State) bool {
+	h1 := c.hash(canonicalState(c.canonical, state))
+	h2 := (h1>>32|h1<<32)*0x9E3779B97F4A7C15 | 1
+	seen := true
+	for i := 0; i < c.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % c.size
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if c.bits[word]&mask == 0 {
+			seen = false
+			c.bits[word] |= mask
+		}
+	}
+	if !seen {
+		*c.count++
+	}
+	return seen
+}
+
+func (c bloomFilter) onExpand(node *node) bool {
+	return c.add(node.state)
+}
+
+func (c bloomFilter) onVisit(node *node) bool {
+	return false
+}
+
+func (c bloomFilter) reset() {
+	for i := range c.bits {
+		c.bits[i] = 0
+	}
+	*c.count = 0
+}
+
+func (c bloomFilter) canonicalized(canonical func(State) State) iconstraint {
+	c.canonical = canonical
+	return c
+}
+
+func (c bloomFilter) falsePositiveRate() float64 {
+	k := float64(c.hashes)
+	return math.Pow(1-math.Exp(-k*float64(*c.count)/float64(c.size)), k)
+}
+
+func (c bloomFilter) String() string {
+	return "BloomFilterConstraint(" + strconv.FormatUint(c.size, 10) + "," + strconv.Itoa(c.hashes) + ")"
+}
+
+// BloomFilterConstraint drops a state when an equal state has been seen before, using a Bloom filter with the
+// given number of bits and number of hashes per state, which must both be at least 1. The memory usage is therefore
+// fixed, at the price that a new state may wrongly be considered as seen and dropped. The estimated probability of
+// that is reported in Result.FalsePositiveRate. For an expected number of n states the optimal number of hashes is
+// about bits / n * ln(2).
+//
+// The (canonical) states are identified by the provided hash function, from which the hashes for the filter are
+// derived, so a good 64-bit hash function should be used.
+//
+// The filter is cleared at the start of each iteration of IDA*. Since a state is dropped regardless of the
+// costs to reach it, the optimal solution is not guaranteed to be found. This constraint is therefore most
+// usable for DepthFirst searches that don't need the optimal solution.
+func BloomFilterConstraint(bits int, hashes int, hash func(State) uint64) Constraint {
+	if bits <= 0 || hashes < 1 {
+		panic("BloomFilterConstraint requires a positive number of bits and at least one hash")
+	}
+	words := (bits + 63) / 64
+	return bloomFilter{make([]uint64, words), uint64(words * 64), hashes, hash, new(int), nil}
+}
+
+// OperatorState can be implemented by states to expose the operator that produced the state from its parent state,
+// as used by the MovePruningConstraint. The operator of a root state is not used.
+type OperatorState interface {
+	State
+
+	// The operator that produced this state from its parent state
+	Operator() interface{}
+}
+
+type movePruningConstraint struct {
+	commute func(a, b interface{}) bool
+	less    func(a, b interface{}) bool
+}
+
+func (c movePruningConstraint) onExpand(node *node) bool {
+	if node.parent == nil || node.parent.parent == nil {
+		return false
+	}
+	previous := node.parent.state.(OperatorState).Operator()
+	next := node.state.(OperatorState).Operator()
+	return c.commute(previous, next) && c.less(next, previous)
+}
+
+func (c movePruningConstraint) onVisit(node *node) bool {
+	return false
+}
+
+func (c movePruningConstraint) reset() {}
+
+func (c movePruningConstraint) String() string {
+	return "MovePruningConstraint"
+}
+
+// MovePruningConstraint drops a state when it is produced by an operator that commutes with the operator that
+// produced its parent state, and that operator is ordered after it. Of the sequences of two independent operators
+// only one order is therefore kept. The states need to implement OperatorState.
+//
+// Two operators commute if applying them in either order results in the same state with the same costs. The
+// provided less function defines the order in which commuting operators are allowed to be applied.
+//
+// No memory is needed and performance is constant time, so this constraint is very suitable for DepthFirst and
+// IDA*, which would otherwise explore all orderings of independent operators.
+func MovePruningConstraint(commute func(a, b interface{}) bool, less func(a, b interface{}) bool) Constraint {
+	return movePruningConstraint{commute, less}
 }
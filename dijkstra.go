@@ -0,0 +1,65 @@
+package solve
+
+// Uniform-cost search from the root states, returning the costs to the targets or to all reachable states
+func distances(ss *solver, targets []interface{}) map[interface{}]float64 {
+	if ss.key == nil {
+		panic("Distances requires the Key of the solver")
+	}
+	key := func(state State) interface{} {
+		return ss.key(canonicalState(ss.canonical, state))
+	}
+	remaining := make(map[interface{}]bool, len(targets))
+	for _, target := range targets {
+		remaining[target] = true
+	}
+	context := ss.newContext()
+	costs := make(map[interface{}]float64)
+	queue := aStar(FIFO().(tieBreaker))
+	for _, rootState := range ss.rootStates {
+		cost := 0.0
+		if !isStepState(rootState) {
+			cost = rootState.Cost(context)
+		}
+		// the nodes are ordered on their costs, the paths are not needed
+		queue.Add(&node{nil, rootState, cost, cost, 0, nil, 0})
+	}
+	for n := queue.Take(); n != nil; n = queue.Take() {
+		k := key(n.state)
+		if _, ok := costs[k]; ok {
+			continue // a cheaper path is already found
+		}
+		costs[k] = n.cost
+		if len(targets) > 0 {
+			delete(remaining, k)
+			if len(remaining) == 0 {
+				break
+			}
+		}
+		if n.depth >= ss.maxDepth {
+			continue
+		}
+		eachChild(n.state, context, func(child State, stepCost float64) bool {
+			cost := n.cost + stepCost
+			if !isStepState(n.state) {
+				cost = child.Cost(context)
+			}
+			if cost > ss.limit {
+				return true
+			}
+			if _, ok := costs[key(child)]; !ok {
+				queue.Add(&node{nil, child, cost, cost, n.depth + 1, nil, 0})
+			}
+			return true
+		})
+	}
+	if len(targets) == 0 {
+		return costs
+	}
+	result := make(map[interface{}]float64, len(targets))
+	for _, target := range targets {
+		if cost, ok := costs[target]; ok {
+			result[target] = cost
+		}
+	}
+	return result
+}
@@ -0,0 +1,567 @@
+package solve
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Serializer converts (canonical) states to bytes and back, so that they can be stored on disk. The bytes identify
+// the state, states with equal bytes are considered equal.
+type Serializer interface {
+	Serialize(state State) []byte
+	Deserialize(data []byte) State
+}
+
+// Disk errors are raised as a panic with a diskFailure by the file operations, and recovered by the search or the
+// map that performs them
+type diskFailure struct {
+	err error
+}
+
+func diskError(err error) {
+	if err != nil {
+		panic(diskFailure{err})
+	}
+}
+
+// Recovers from a disk failure, of which the error is stored in err unless it already contains an error. Should be
+// deferred directly. Other panics are propagated.
+func recoverDiskError(err *error) {
+	if r := recover(); r != nil {
+		f, ok := r.(diskFailure)
+		if !ok {
+			panic(r)
+		}
+		if *err == nil {
+			*err = fmt.Errorf("disk error: %v", f.err)
+		}
+	}
+}
+
+// Files with sorted records. A record consists of a key and a value, each prefixed with its length. A sparse index
+// with every indexInterval'th key is kept in memory to support lookups.
+const indexInterval = 64
+
+type record struct {
+	key   []byte
+	value []byte
+}
+
+type indexEntry struct {
+	key    []byte
+	offset int64
+}
+
+type recordFile struct {
+	file  *os.File
+	size  int64
+	count int
+	index []indexEntry
+}
+
+type recordWriter struct {
+	file   *os.File
+	w      *bufio.Writer
+	offset int64
+	count  int
+	index  []indexEntry
+	last   []byte
+	closed bool
+}
+
+func createRecordFile(dir string) *recordWriter {
+	file, err := os.CreateTemp(dir, "records")
+	diskError(err)
+	return &recordWriter{file: file, w: bufio.NewWriter(file)}
+}
+
+// Writes the record, records with the same key as the previous record are skipped
+func (w *recordWriter) write(key, value []byte) {
+	if w.count > 0 && bytes.Equal(key, w.last) {
+		return
+	}
+	if w.count%indexInterval == 0 {
+		w.index = append(w.index, indexEntry{append([]byte(nil), key...), w.offset})
+	}
+	var buf [binary.MaxVarintLen64]byte
+	for _, data := range [][]byte{key, value} {
+		n := binary.PutUvarint(buf[:], uint64(len(data)))
+		_, err := w.w.Write(buf[:n])
+		diskError(err)
+		_, err = w.w.Write(data)
+		diskError(err)
+		w.offset += int64(n + len(data))
+	}
+	w.last = append(w.last[:0], key...)
+	w.count++
+}
+
+func (w *recordWriter) close() *recordFile {
+	diskError(w.w.Flush())
+	w.closed = true
+	return &recordFile{w.file, w.offset, w.count, w.index}
+}
+
+// Removes the file if it is not closed, to be deferred in case writing the file fails
+func (w *recordWriter) abort() {
+	if !w.closed {
+		w.file.Close()
+		os.Remove(w.file.Name())
+	}
+}
+
+func (f *recordFile) reader() *recordReader {
+	return &recordReader{r: bufio.NewReader(io.NewSectionReader(f.file, 0, f.size))}
+}
+
+// Returns the value of the record with the given key
+func (f *recordFile) lookup(key []byte) ([]byte, bool) {
+	i := sort.Search(len(f.index), func(i int) bool {
+		return bytes.Compare(f.index[i].key, key) > 0
+	}) - 1
+	if i < 0 {
+		return nil, false
+	}
+	end := f.size
+	if i+1 < len(f.index) {
+		end = f.index[i+1].offset
+	}
+	offset := f.index[i].offset
+	r := recordReader{r: bufio.NewReader(io.NewSectionReader(f.file, offset, end-offset))}
+	for r.next() {
+		switch bytes.Compare(r.key, key) {
+		case 0:
+			return r.value, true
+		case 1:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func (f *recordFile) remove() {
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+type recordReader struct {
+	r     *bufio.Reader
+	key   []byte
+	value []byte
+}
+
+func (r *recordReader) read() ([]byte, bool) {
+	length, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, false
+	}
+	diskError(err)
+	data := make([]byte, length)
+	_, err = io.ReadFull(r.r, data)
+	diskError(err)
+	return data, true
+}
+
+// Reads the next record, returns false if there are no more records
+func (r *recordReader) next() bool {
+	key, ok := r.read()
+	if !ok {
+		return false
+	}
+	value, ok := r.read()
+	if !ok {
+		diskError(io.ErrUnexpectedEOF)
+	}
+	r.key, r.value = key, value
+	return true
+}
+
+// Sorts the records and writes them to a new file. Of the records with equal keys only the first is kept.
+func writeSorted(dir string, records []record) *recordFile {
+	sort.SliceStable(records, func(i, j int) bool {
+		return bytes.Compare(records[i].key, records[j].key) < 0
+	})
+	w := createRecordFile(dir)
+	defer w.abort()
+	for _, r := range records {
+		w.write(r.key, r.value)
+	}
+	return w.close()
+}
+
+// Heap of readers for merging sorted files, ordered by their current key and then by the order of the files
+type mergeHeap []*mergeReader
+
+type mergeReader struct {
+	*recordReader
+	order int
+}
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	if c := bytes.Compare(h[i].key, h[j].key); c != 0 {
+		return c < 0
+	}
+	return h[i].order < h[j].order
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeReader))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[0 : n-1]
+	return item
+}
+
+// Merges the sorted files into a new sorted file. Of the records with equal keys only the one from the first file is
+// kept.
+func mergeFiles(dir string, files []*recordFile) *recordFile {
+	h := make(mergeHeap, 0, len(files))
+	for i, f := range files {
+		r := f.reader()
+		if r.next() {
+			h = append(h, &mergeReader{r, i})
+		}
+	}
+	heap.Init(&h)
+	w := createRecordFile(dir)
+	defer w.abort()
+	for len(h) > 0 {
+		r := h[0]
+		w.write(r.key, r.value)
+		if r.next() {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return w.close()
+}
+
+// Writes the records of the sorted file that don't occur in any of the other sorted files to a new file
+func subtractFiles(dir string, file *recordFile, others []*recordFile) *recordFile {
+	var readers []*recordReader
+	for _, other := range others {
+		r := other.reader()
+		if r.next() {
+			readers = append(readers, r)
+		}
+	}
+	w := createRecordFile(dir)
+	defer w.abort()
+	r := file.reader()
+	for r.next() {
+		found := false
+		for i := 0; i < len(readers); i++ {
+			other := readers[i]
+			c := bytes.Compare(other.key, r.key)
+			for c < 0 {
+				if !other.next() {
+					break
+				}
+				c = bytes.Compare(other.key, r.key)
+			}
+			if c < 0 {
+				// exhausted
+				readers = append(readers[:i], readers[i+1:]...)
+				i--
+				continue
+			}
+			if c == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			w.write(r.key, r.value)
+		}
+	}
+	return w.close()
+}
+
+// DiskCPMap is a CPMap that spills to disk when the number of states in memory exceeds the configured maximum.
+// The states on disk are stored in sorted files which are merged when there are too many of them. Lookups on disk
+// use a sparse index in memory, so the memory usage is roughly 1/64 of the memory that is needed to keep all
+// states in memory.
+//
+// After a disk error the map keeps all new states in memory, and states on disk that can not be read are reported as
+// unknown, which only makes the constraint less effective. The first error is returned by Err. Close removes the files
+// from disk.
+type DiskCPMap struct {
+	dir        string
+	serializer Serializer
+	memory     int
+
+	files  string // directory with the files of this map, created when needed
+	values map[string]float64
+	runs   []*recordFile // newest first
+	err    error
+}
+
+const maxRuns = 16
+
+// NewDiskCPMap creates a CPMap that keeps at most memory states in memory and spills the rest to files in a new
+// directory in dir, or in the default directory for temporary files if dir is empty.
+func NewDiskCPMap(dir string, serializer Serializer, memory int) *DiskCPMap {
+	return &DiskCPMap{dir: dir, serializer: serializer, memory: memory, values: make(map[string]float64)}
+}
+
+func (m *DiskCPMap) Get(state State) (value float64, found bool) {
+	defer recoverDiskError(&m.err)
+	key := m.serializer.Serialize(state)
+	if value, ok := m.values[string(key)]; ok {
+		return value, true
+	}
+	for _, run := range m.runs {
+		if value, ok := run.lookup(key); ok {
+			return math.Float64frombits(binary.LittleEndian.Uint64(value)), true
+		}
+	}
+	return 0, false
+}
+
+func (m *DiskCPMap) Put(state State, value float64) {
+	defer recoverDiskError(&m.err)
+	m.values[string(m.serializer.Serialize(state))] = value
+	if len(m.values) >= m.memory && m.err == nil {
+		m.spill()
+	}
+}
+
+// Err returns the first disk error of the map, or nil if there was none
+func (m *DiskCPMap) Err() error {
+	return m.err
+}
+
+func (m *DiskCPMap) spill() {
+	if m.files == "" {
+		files, err := os.MkdirTemp(m.dir, "cpmap")
+		diskError(err)
+		m.files = files
+	}
+	records := make([]record, 0, len(m.values))
+	for key, value := range m.values {
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, math.Float64bits(value))
+		records = append(records, record{[]byte(key), data})
+	}
+	m.runs = append([]*recordFile{writeSorted(m.files, records)}, m.runs...)
+	m.values = make(map[string]float64)
+	if len(m.runs) > maxRuns {
+		merged := mergeFiles(m.files, m.runs)
+		for _, run := range m.runs {
+			run.remove()
+		}
+		m.runs = []*recordFile{merged}
+	}
+}
+
+func (m *DiskCPMap) Clear() {
+	m.Close()
+	m.values = make(map[string]float64)
+	m.err = nil
+}
+
+// Close removes the files of the map from disk
+func (m *DiskCPMap) Close() {
+	for _, run := range m.runs {
+		run.remove()
+	}
+	m.runs = nil
+	if m.files != "" {
+		os.RemoveAll(m.files)
+		m.files = ""
+	}
+}
+
+// Layered breadth-first search in external memory. Each layer is stored as a sorted file of serialized states. The
+// children of a layer are sorted in runs that fit in memory, which are merged and from which the states of all
+// previous layers are subtracted to form the next layer. Since the parents are not stored, the path to a goal is
+// reconstructed by searching the previous layers backwards for a parent of each state.
+type externalBFS struct {
+	rootStates []State
+	context    Context
+	serializer Serializer
+	dir        string
+	memory     int
+	maxDepth   int
+	canonical  func(State) State
+
+	started  bool
+	files    string // directory with the files of this search, empty if removed
+	layers   []*recordFile
+	reader   *recordReader // reader of the last layer, nil when the search is completed
+	pending  State         // goal state that is not expanded yet
+	children []record
+	runs     []*recordFile
+
+	visited  int
+	expanded int
+}
+
+func newExternalBFS(rootStates []State, context Context, serializer Serializer, dir string, memory int, maxDepth int, canonical func(State) State) *externalBFS {
+	if serializer == nil {
+		panic("External breadth-first search requires a serializer, see Solver.ExternalMemory")
+	}
+	return &externalBFS{rootStates: rootStates, context: context, serializer: serializer, dir: dir, memory: memory,
+		maxDepth: maxDepth, canonical: canonical}
+}
+
+// Creates the directory with the files of the search and the first layer with the root states
+func (b *externalBFS) start() {
+	files, err := os.MkdirTemp(b.dir, "bfs")
+	diskError(err)
+	b.files = files
+	var records []record
+	for _, rootState := range b.rootStates {
+		records = append(records, record{b.serialize(rootState), nil})
+	}
+	layer := writeSorted(files, records)
+	b.layers = append(b.layers, layer)
+	b.reader = layer.reader()
+}
+
+func (b *externalBFS) serialize(state State) []byte {
+	return b.serializer.Serialize(canonicalState(b.canonical, state))
+}
+
+func (b *externalBFS) expand(state State) {
+	eachChild(state, b.context, func(child State, stepCost float64) bool {
+		b.children = append(b.children, record{b.serialize(child), nil})
+		b.expanded++
+		if len(b.children) >= b.memory {
+			b.runs = append(b.runs, writeSorted(b.files, b.children))
+			b.children = nil
+		}
+		return true
+	})
+}
+
+// Creates the next layer from the children of the current layer, returns false if it is empty
+func (b *externalBFS) nextLayer() bool {
+	if len(b.layers) > b.maxDepth {
+		return false
+	}
+	b.runs = append(b.runs, writeSorted(b.files, b.children))
+	b.children = nil
+	candidates := mergeFiles(b.files, b.runs)
+	for _, run := range b.runs {
+		run.remove()
+	}
+	b.runs = nil
+	layer := subtractFiles(b.files, candidates, b.layers)
+	candidates.remove()
+	if layer.count == 0 {
+		layer.remove()
+		return false
+	}
+	b.layers = append(b.layers, layer)
+	b.reader = layer.reader()
+	return true
+}
+
+// Removes the files of the search, after which the search is completed
+func (b *externalBFS) close() {
+	for _, file := range append(b.layers, b.runs...) {
+		file.remove()
+	}
+	b.layers, b.runs, b.children = nil, nil, nil
+	b.reader = nil
+	b.pending = nil
+	if b.files != "" {
+		os.RemoveAll(b.files)
+		b.files = ""
+	}
+}
+
+// Continues the search until the next goal, the search is closed when it fails with a disk error
+func (b *externalBFS) run() result {
+	r, err := b.search()
+	if err != nil {
+		b.close()
+		return result{nil, math.Inf(1), false, b.visited, b.expanded, nil, err}
+	}
+	return r
+}
+
+func (b *externalBFS) search() (r result, err error) {
+	defer recoverDiskError(&err)
+	if !b.started {
+		b.started = true
+		b.start()
+	}
+	if b.pending != nil {
+		b.expand(b.pending)
+		b.pending = nil
+	}
+	for b.reader != nil {
+		for b.reader.next() {
+			state := b.serializer.Deserialize(b.reader.key)
+			b.visited++
+			if state.IsGoal(b.context) {
+				b.pending = state
+				n := b.path(len(b.layers)-1, b.reader.key)
+				next := b.run
+				return result{n, math.Inf(1), false, b.visited, b.expanded, &next, nil}, nil
+			}
+			b.expand(state)
+		}
+		if !b.nextLayer() {
+			b.close()
+		}
+	}
+	return result{nil, math.Inf(1), false, b.visited, b.expanded, nil, nil}, nil
+}
+
+// Reconstructs the path to the state with the given key in the given layer
+func (b *externalBFS) path(depth int, key []byte) *node {
+	states := make([]State, depth+1)
+	stepCosts := make([]float64, depth+1)
+	states[0] = b.serializer.Deserialize(key)
+	for d := depth - 1; d >= 0; d-- {
+		r := b.layers[d].reader()
+		found := false
+		for !found && r.next() {
+			parent := b.serializer.Deserialize(r.key)
+			eachChild(parent, b.context, func(child State, stepCost float64) bool {
+				if bytes.Equal(b.serialize(child), key) {
+					states[d+1], stepCosts[d+1] = child, stepCost
+					states[d] = parent
+					key = r.key
+					found = true
+				}
+				return !found
+			})
+		}
+		if !found {
+			panic("Parent state not found in previous layer, is the serializer deterministic?")
+		}
+	}
+	n := rootNode(states[0], b.context)
+	for d := 1; d <= depth; d++ {
+		cost := n.cost + stepCosts[d]
+		if math.IsNaN(stepCosts[d]) {
+			cost = states[d].Cost(b.context)
+		}
+		n = &node{n, states[d], cost, cost + b.context.evaluate(states[d], cost, d, math.Inf(1)), d, nil, 0}
+	}
+	return n
+}
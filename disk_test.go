@@ -0,0 +1,173 @@
+package solve
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+type swapSerializer struct{}
+
+func (swapSerializer) Serialize(s State) []byte {
+	vector := s.(swapState).vector
+	return vector[:]
+}
+
+func (swapSerializer) Deserialize(data []byte) State {
+	var s swapState
+	copy(s.vector[:], data)
+	return s
+}
+
+type nodeSerializer struct {
+	graph graph
+}
+
+func (s nodeSerializer) Serialize(st State) []byte {
+	return []byte(st.(stepState).node)
+}
+
+func (s nodeSerializer) Deserialize(data []byte) State {
+	return stepState{s.graph, string(data)}
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected files to be removed, but found %v", entries)
+	}
+}
+
+func TestExternalBreadthFirst(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"a", 1}, {"b", 1}, {"c", 2}}
+	g["b"] = []edge{{"c", 1}, {"d", 2}}
+	g["c"] = []edge{{"a", 1}, {"D", 1}, {"E", 3}}
+	g["D"] = []edge{{"E", 1}}
+	dir := t.TempDir()
+	solver := NewSolver(stepState{g, "a"}).
+		Algorithm(ExternalBreadthFirst).
+		ExternalMemory(dir, nodeSerializer{g}, 1)
+	var actual []goalCost
+	var paths []string
+	for result := range solver.SolveAll() {
+		actual = append(actual, goalCost{result.GoalState().(stepState).node, result.Cost})
+		path := ""
+		for _, s := range result.Solution {
+			path += s.(stepState).node
+		}
+		paths = append(paths, path)
+	}
+	expected := []goalCost{{"D", 3}, {"E", 5}}
+	if !equalGoalCost(actual, expected) {
+		t.Errorf("Expected %v but found %v", expected, actual)
+	}
+	if paths[0] != "acD" || paths[1] != "acE" {
+		t.Errorf("Expected paths [acD acE], but found %v", paths)
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestExternalBreadthFirstVisitsAllStatesOnce(t *testing.T) {
+	dir := t.TempDir()
+	solver := NewSolver(swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}).
+		Algorithm(ExternalBreadthFirst).
+		ExternalMemory(dir, swapSerializer{}, 1000)
+	result := solver.Solve()
+	if len(result.Solution) != 11 {
+		t.Errorf("Expected solution in 10 steps, but found %v", len(result.Solution)-1)
+	}
+	for result.Solved() {
+		result = solver.Solve()
+	}
+	if result.Visited != 40320 {
+		t.Errorf("Expected all 40320 states to be visited once, but visited %v", result.Visited)
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestExternalBreadthFirstClose(t *testing.T) {
+	dir := t.TempDir()
+	solver := NewSolver(swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}).
+		Algorithm(ExternalBreadthFirst).
+		ExternalMemory(dir, swapSerializer{}, 1000)
+	if result := solver.Solve(); !result.Solved() {
+		t.Fatalf("Expected a solution")
+	}
+	solver.Close()
+	assertEmptyDir(t, dir)
+	if !solver.Completed() || solver.Solve().Solved() {
+		t.Errorf("Expected no more solutions after close")
+	}
+}
+
+func TestExternalBreadthFirstDiskError(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	result := NewSolver(swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}).
+		Algorithm(ExternalBreadthFirst).
+		ExternalMemory(dir, swapSerializer{}, 1000).
+		Solve()
+	if result.Err == nil || result.Solved() {
+		t.Errorf("Expected a disk error, but was %v", result)
+	}
+}
+
+type stringSerializer struct{}
+
+func (stringSerializer) Serialize(s State) []byte {
+	return []byte(s.(dummyState).name)
+}
+
+func (stringSerializer) Deserialize(data []byte) State {
+	return dummyState{nil, string(data)}
+}
+
+func TestDiskCPMap(t *testing.T) {
+	dir := t.TempDir()
+	m := NewDiskCPMap(dir, stringSerializer{}, 100)
+	state := func(i int) State {
+		return dummyState{nil, strconv.Itoa(i)}
+	}
+	for i := 0; i < 5000; i++ {
+		m.Put(state(i), float64(i))
+	}
+	for i := 0; i < 5000; i += 2 {
+		m.Put(state(i), float64(-i))
+	}
+	for i := 0; i < 5000; i++ {
+		expected := float64(i)
+		if i%2 == 0 {
+			expected = float64(-i)
+		}
+		if value, ok := m.Get(state(i)); !ok || value != expected {
+			t.Errorf("Expected %v for state %v, but was %v, %v", expected, i, value, ok)
+			return
+		}
+	}
+	if _, ok := m.Get(state(5000)); ok {
+		t.Error("Expected unknown state not to be found")
+	}
+	m.Clear()
+	if _, ok := m.Get(state(1)); ok {
+		t.Error("Expected state not to be found after clear")
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestDiskCPMapDiskError(t *testing.T) {
+	m := NewDiskCPMap(filepath.Join(t.TempDir(), "missing"), stringSerializer{}, 10)
+	for i := 0; i < 100; i++ {
+		m.Put(dummyState{nil, strconv.Itoa(i)}, float64(i))
+	}
+	if m.Err() == nil {
+		t.Error("Expected a disk error")
+	}
+	if value, ok := m.Get(dummyState{nil, "50"}); !ok || value != 50 {
+		t.Errorf("Expected the states to be kept in memory, but was %v, %v", value, ok)
+	}
+	m.Close()
+}
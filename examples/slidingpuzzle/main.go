@@ -238,6 +238,8 @@ func generateAndSolve(seed int64) solve.Result {
 	start := time.Now()
 	result := solve.NewSolver(puzzle).
 		Algorithm(solve.Astar).
+		TieBreaking(solve.HighestCostFirst()).
+		IntegerCosts(true).
 		//Constraint(noLoopConstraint(12)).
 		Constraint(cheapestPathConstraint()).
 		Solve()
@@ -38,9 +38,33 @@ func (s sudoku) Expand(solve.Context) []solve.State {
 	return children
 }
 
+// Iterates over the valid values for the current position, so the depth-first
+// search only creates the next child when the previous one is a dead end
+type successors struct {
+	sudoku sudoku
+	value  int
+}
+
+func (it *successors) Next() (solve.State, bool) {
+	for it.value < 9 {
+		it.value++
+		if child := it.sudoku.withValue(it.value); child != nil {
+			return *child, true
+		}
+	}
+	return nil, false
+}
+
+func (s sudoku) Successors(solve.Context) solve.Successors {
+	return &successors{s, 0}
+}
+
 // Returns a new sudoku with the value set at the current position,
 // or nil if that would be invalid
 func (s sudoku) withValue(value int) *sudoku {
+	if s.position == 9*9 {
+		return nil // all positions are filled
+	}
 	row := s.position / 9
 	col := s.position % 9
 	copy := s
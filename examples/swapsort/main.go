@@ -77,6 +77,11 @@ func (s swapState) Expand(ctx solve.Context) []solve.State {
 	return steps
 }
 
+// For move pruning constraint
+func (s swapState) Operator() interface{} {
+	return s.op
+}
+
 func (s swapState) IsGoal(ctx solve.Context) bool {
 	return s.vector == context(ctx).goal
 }
@@ -135,6 +140,26 @@ func (c *cpMap) Clear() {
 	*c = make(cpMap)
 }
 
+// swapping non-overlapping pairs of elements commutes
+func commute(a, b interface{}) bool {
+	d := a.(int) - b.(int)
+	return d > 1 || d < -1
+}
+
+func less(a, b interface{}) bool {
+	return a.(int) < b.(int)
+}
+
+// For transposition table constraint, FNV-1a hash of the vector
+func hash(state solve.State) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range state.(swapState).vector {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
 func main() {
 	f, err := os.Create("cpu.prof")
 	if err != nil {
@@ -145,12 +170,13 @@ func main() {
 
 	context, state := swapProblem([]byte{7, 6, 5, 4, 3, 2, 1, 0})
 	fmt.Printf("Sorting %v in minimal number of swaps of neighbouring elements\n", state)
-	constraintMap := make(cpMap)
 	start := time.Now()
 	result := solve.NewSolver(state).
 		Context(context).
 		Algorithm(solve.IDAstar).
-		Constraint(solve.CheapestPathConstraint(&constraintMap)).
+		//Constraint(solve.CheapestPathConstraint(&cpMap{})).
+		//Constraint(solve.MovePruningConstraint(commute, less)).
+		Constraint(solve.TranspositionTableConstraint(1<<20, hash, solve.ReplaceByDepth)).
 		Solve()
 
 	fmt.Printf("visited: %d, expanded %d, time %0.2fs\n", result.Visited, result.Expanded, time.Since(start).Seconds())
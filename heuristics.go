@@ -0,0 +1,141 @@
+package solve
+
+import (
+	"container/list"
+	"math"
+)
+
+// HeuristicFunc estimates the costs to reach a goal from the state, like State.Heuristic
+type HeuristicFunc func(state State, ctx Context) float64
+
+// Max returns the maximum of the heuristics, which is admissible if all heuristics are admissible
+func Max(heuristics ...HeuristicFunc) HeuristicFunc {
+	return func(state State, ctx Context) float64 {
+		max := 0.0
+		for _, h := range heuristics {
+			max = math.Max(max, h(state, ctx))
+		}
+		return max
+	}
+}
+
+// Sum returns the sum of the heuristics. Note that the sum is typically not admissible, unless the heuristics each
+// count a disjoint part of the costs.
+func Sum(heuristics ...HeuristicFunc) HeuristicFunc {
+	return func(state State, ctx Context) float64 {
+		sum := 0.0
+		for _, h := range heuristics {
+			sum += h(state, ctx)
+		}
+		return sum
+	}
+}
+
+// Weighted returns the heuristic multiplied by the weight. Can be combined with Sum for a weighted sum of heuristics.
+// Weights greater than 1 typically find solutions faster at the expense of optimality.
+func Weighted(weight float64, h HeuristicFunc) HeuristicFunc {
+	return func(state State, ctx Context) float64 {
+		return weight * h(state, ctx)
+	}
+}
+
+// SwitchAtDepth returns the heuristic shallow for the states with a depth smaller than depth, and deep for the other
+// states. Useful for example to use an expensive heuristic only near the root of the search tree.
+func SwitchAtDepth(depth int, shallow HeuristicFunc, deep HeuristicFunc) HeuristicFunc {
+	return func(state State, ctx Context) float64 {
+		if ctx.Depth() < depth {
+			return shallow(state, ctx)
+		}
+		return deep(state, ctx)
+	}
+}
+
+// Cached returns the heuristic with the values cached by the key of the states. The key must be a valid map key and
+// be equal for equal states. The cache is never cleared. Should not wrap heuristics that depend on the context, like
+// Lazy, which depends on the bound, and SwitchAtDepth, which depends on the depth, since the cached value of a state
+// is then returned in another context.
+func Cached(key func(State) interface{}, h HeuristicFunc) HeuristicFunc {
+	cache := make(map[interface{}]float64)
+	return func(state State, ctx Context) float64 {
+		k := key(state)
+		if value, ok := cache[k]; ok {
+			return value
+		}
+		value := h(state, ctx)
+		cache[k] = value
+		return value
+	}
+}
+
+// Lazy returns the maximum of the heuristics, but evaluates them in order and stops as soon as the value of the state
+// exceeds the current bound, see Context.Bound. The cheapest heuristics should therefore come first. Especially
+// useful for IDA*, where most of the states in the last iterations exceed the contour.
+func Lazy(heuristics ...HeuristicFunc) HeuristicFunc {
+	return func(state State, ctx Context) float64 {
+		remaining := ctx.Bound() - ctx.PathCost()
+		max := 0.0
+		for _, h := range heuristics {
+			max = math.Max(max, h(state, ctx))
+			if max > remaining {
+				break
+			}
+		}
+		return max
+	}
+}
+
+// A root state with the heuristic that is used instead of its own heuristic
+type heuristicWrapper struct {
+	State
+	h HeuristicFunc
+}
+
+func (w heuristicWrapper) Heuristic(ctx Context) float64 {
+	return w.h(w.State, ctx)
+}
+
+// WithHeuristic returns the state with the heuristic h instead of its own heuristic, for example a combination of
+// heuristics like Max or Lazy. NewSolver and NewMultiSolver unwrap the root state and use h as the heuristic of the
+// solver, like Solver.Heuristic, so h is used for all states of the search and the states themselves are passed to
+// the constraints, Key, Canonicalize and the optional interfaces like StepState and LazyState. The wrapped root
+// states of a solver must therefore have the same heuristic.
+func WithHeuristic(state State, h HeuristicFunc) State {
+	return heuristicWrapper{state, h}
+}
+
+// Bounded cache of heuristic values, the least recently used value is evicted when the cache is full
+type heuristicCache struct {
+	key     func(State) interface{}
+	size    int
+	entries map[interface{}]*list.Element
+	lru     *list.List // most recently used first
+}
+
+type cacheEntry struct {
+	key   interface{}
+	value float64
+}
+
+func newHeuristicCache(key func(State) interface{}, size int) *heuristicCache {
+	return &heuristicCache{key, size, make(map[interface{}]*list.Element), list.New()}
+}
+
+func (c *heuristicCache) get(key interface{}) (float64, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return 0, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true
+}
+
+func (c *heuristicCache) put(key interface{}, value float64) {
+	if c.size <= 0 {
+		return
+	}
+	if c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		delete(c.entries, c.lru.Remove(oldest).(*cacheEntry).key)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, value})
+}
@@ -0,0 +1,250 @@
+package solve
+
+import (
+	"container/heap"
+	"math"
+)
+
+// IncrementalSolver finds the cheapest solution again after the costs or children of some states have changed, reusing
+// the results of the previous search (LPA*). This is much faster than a new search when the changes only affect a
+// small part of the search space, like a door that closes in a route planning problem.
+//
+// The states must implement StepState, and are identified by a key. The states for which the transitions have
+// changed are reported with Invalidate, after which Replan returns the new cheapest solution. Which states are
+// goals may not change. The heuristic must be consistent for the solutions to be optimal.
+type IncrementalSolver struct {
+	key     func(State) interface{}
+	context Context
+	roots   map[interface{}]bool
+	entries map[interface{}]*lpaEntry
+	queue   lpaQueue
+	goal    *lpaEntry // virtual state that is the successor of all goal states
+
+	visited  int
+	expanded int
+}
+
+// Virtual goal state, the key of the virtual goal
+type lpaGoal struct{}
+
+type lpaEdge struct {
+	key  interface{}
+	cost float64
+}
+
+type lpaEntry struct {
+	key          interface{}
+	state        State
+	g            float64
+	rhs          float64 // the costs of the cheapest path according to the g of the predecessors
+	h            float64
+	successors   []lpaEdge // nil if not expanded
+	expanded     bool
+	predecessors map[interface{}]float64
+	index        int // index in the queue, -1 if not queued
+	k1, k2       float64
+}
+
+// NewIncrementalSolver creates an incremental solver for the root states. The keys must be valid map keys and equal
+// for equal states. The context is passed to the methods of the states.
+//
+// The heuristic of a state is computed once and reused for all paths to the state, so heuristics that depend on the
+// path costs or the depth in the context, like SwitchAtDepth, are not supported. The bound in the context is
+// infinite.
+func NewIncrementalSolver(key func(State) interface{}, context interface{}, roots ...StepState) *IncrementalSolver {
+	s := &IncrementalSolver{key, Context{context, nil, nil}, make(map[interface{}]bool),
+		make(map[interface{}]*lpaEntry), nil, nil, 0, 0}
+	s.goal = s.entry(lpaGoal{}, nil)
+	for _, root := range roots {
+		e := s.entry(key(root), root)
+		s.roots[e.key] = true
+		e.rhs = 0
+		s.update(e)
+	}
+	return s
+}
+
+// Returns the entry of the state, which is created if it doesn't exist yet
+func (s *IncrementalSolver) entry(key interface{}, state State) *lpaEntry {
+	if e, ok := s.entries[key]; ok {
+		return e
+	}
+	h := 0.0
+	if state != nil {
+		h = state.Heuristic(s.context)
+	}
+	e := &lpaEntry{key, state, math.Inf(1), math.Inf(1), h, nil, false, make(map[interface{}]float64), -1, 0, 0}
+	s.entries[key] = e
+	return e
+}
+
+// Determines the successors of the state and registers the state as their predecessor
+func (s *IncrementalSolver) expand(e *lpaEntry) {
+	e.expanded = true
+	e.successors = nil
+	s.expanded++
+	if e.state.IsGoal(s.context) {
+		e.successors = append(e.successors, lpaEdge{s.goal.key, 0})
+	}
+	for _, step := range e.state.(StepState).ExpandSteps(s.context) {
+		child := s.entry(s.key(step.State), step.State)
+		e.successors = append(e.successors, lpaEdge{child.key, step.Cost})
+	}
+	for _, edge := range e.successors {
+		predecessors := s.entries[edge.key].predecessors
+		if cost, ok := predecessors[e.key]; !ok || edge.cost < cost {
+			predecessors[e.key] = edge.cost
+		}
+	}
+}
+
+func (s *IncrementalSolver) successors(e *lpaEntry) []lpaEdge {
+	if !e.expanded && e.state != nil {
+		s.expand(e)
+	}
+	return e.successors
+}
+
+// Recomputes the rhs of the state and puts it in the queue if it is inconsistent
+func (s *IncrementalSolver) update(e *lpaEntry) {
+	if !s.roots[e.key] {
+		e.rhs = math.Inf(1)
+		for key, cost := range e.predecessors {
+			e.rhs = math.Min(e.rhs, s.entries[key].g+cost)
+		}
+	}
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+	if e.g != e.rhs {
+		e.k2 = math.Min(e.g, e.rhs)
+		e.k1 = e.k2 + e.h
+		heap.Push(&s.queue, e)
+	}
+}
+
+func (s *IncrementalSolver) goalKey() (float64, float64) {
+	k2 := math.Min(s.goal.g, s.goal.rhs)
+	return k2 + s.goal.h, k2
+}
+
+func (s *IncrementalSolver) computeShortestPath() {
+	for s.queue.Len() > 0 {
+		top := s.queue[0]
+		k1, k2 := s.goalKey()
+		// states with the same key as the goal are processed as well, since they may be goal states of which the
+		// costs increased
+		if keyLess(k1, k2, top.k1, top.k2) && s.goal.rhs == s.goal.g {
+			return
+		}
+		e := heap.Pop(&s.queue).(*lpaEntry)
+		s.visited++
+		if e.g > e.rhs {
+			e.g = e.rhs
+			for _, edge := range s.successors(e) {
+				s.update(s.entries[edge.key])
+			}
+		} else {
+			e.g = math.Inf(1)
+			for _, edge := range s.successors(e) {
+				s.update(s.entries[edge.key])
+			}
+			s.update(e)
+		}
+	}
+}
+
+func keyLess(a1, a2, b1, b2 float64) bool {
+	return a1 < b1 || a1 == b1 && a2 < b2
+}
+
+// Invalidate reports that the costs or children of the state have changed. The successors of the state are
+// determined again when the state is needed by the next call to Replan.
+func (s *IncrementalSolver) Invalidate(state State) {
+	e, ok := s.entries[s.key(state)]
+	if !ok {
+		return // the state is not reached yet
+	}
+	e.state = state
+	if !e.expanded {
+		return // the successors are determined from the new state when it is expanded
+	}
+	old := e.successors
+	for _, edge := range old {
+		delete(s.entries[edge.key].predecessors, e.key)
+	}
+	s.expand(e)
+	for _, edge := range old {
+		s.update(s.entries[edge.key])
+	}
+	for _, edge := range e.successors {
+		s.update(s.entries[edge.key])
+	}
+}
+
+// Replan returns the cheapest solution for the current transitions. The first call performs a complete search, the
+// next calls only process the states that are affected by the invalidated states. Visited and Expanded of the result
+// are the number of states that are processed and expanded by this call.
+func (s *IncrementalSolver) Replan() Result {
+	s.visited, s.expanded = 0, 0
+	s.computeShortestPath()
+	if math.IsInf(s.goal.g, 1) {
+		return Result{Visited: s.visited, Expanded: s.expanded}
+	}
+	// search back from the goal to a root state over the predecessors on a cheapest path, which are the predecessors
+	// of which the costs plus the costs of the step equal the costs of the state. With steps of zero costs these may
+	// form cycles, so each state is visited only once.
+	next := map[*lpaEntry]*lpaEntry{s.goal: nil} // the successor towards the goal
+	queue := []*lpaEntry{s.goal}
+	var root *lpaEntry
+	for len(queue) > 0 && root == nil {
+		e := queue[0]
+		queue = queue[1:]
+		for key, cost := range e.predecessors {
+			p := s.entries[key]
+			if _, ok := next[p]; ok || p.g+cost != e.g {
+				continue
+			}
+			next[p] = e
+			if s.roots[p.key] {
+				root = p
+				break
+			}
+			queue = append(queue, p)
+		}
+	}
+	var path []State
+	for e := root; e != nil && e != s.goal; e = next[e] {
+		path = append(path, e.state)
+	}
+	return Result{Solution: path, Cost: s.goal.g, Visited: s.visited, Expanded: s.expanded}
+}
+
+// Priority queue of the inconsistent states, ordered on their keys
+type lpaQueue []*lpaEntry
+
+func (q lpaQueue) Len() int { return len(q) }
+
+func (q lpaQueue) Less(i, j int) bool {
+	return keyLess(q[i].k1, q[i].k2, q[j].k1, q[j].k2)
+}
+
+func (q lpaQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *lpaQueue) Push(x interface{}) {
+	e := x.(*lpaEntry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *lpaQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	e.index = -1
+	return e
+}
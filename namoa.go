@@ -0,0 +1,187 @@
+package solve
+
+import (
+	"container/heap"
+)
+
+// MultiObjectiveState is a state with multiple independent costs, like time and risk, to be solved with
+// Solver.SolvePareto. The costs are cumulative like State.Cost, and the vectors must have the same length for all
+// states. Cost and Heuristic are not used.
+type MultiObjectiveState interface {
+	State
+
+	// The costs to reach this state for each objective
+	Costs(ctx Context) []float64
+
+	// Estimated costs to reach a goal for each objective. The Pareto front is only complete if the heuristic of each
+	// objective is admissible.
+	Heuristics(ctx Context) []float64
+}
+
+// Returns true if each element of a is lower than or equal to the corresponding element of b
+func weaklyDominates(a []float64, b []float64) bool {
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalCosts(a []float64, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if a is lower than b, comparing the first objective first
+func lexicographicLess(a []float64, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// A path to a state with its costs
+type label struct {
+	parent *label
+	state  State
+	g      []float64
+	f      []float64
+	depth  int
+	seq    int
+	index  int // index in the open list, -1 if closed
+}
+
+// Open list ordered lexicographically on f, so the first label is never dominated by the other labels
+type openLabels []*label
+
+func (o openLabels) Len() int { return len(o) }
+
+func (o openLabels) Less(i, j int) bool {
+	if equalCosts(o[i].f, o[j].f) {
+		return o[i].seq < o[j].seq
+	}
+	return lexicographicLess(o[i].f, o[j].f)
+}
+
+func (o openLabels) Swap(i, j int) {
+	o[i], o[j] = o[j], o[i]
+	o[i].index = i
+	o[j].index = j
+}
+
+func (o *openLabels) Push(x interface{}) {
+	l := x.(*label)
+	l.index = len(*o)
+	*o = append(*o, l)
+}
+
+func (o *openLabels) Pop() interface{} {
+	old := *o
+	l := old[len(old)-1]
+	*o = old[:len(old)-1]
+	l.index = -1
+	return l
+}
+
+// NAMOA*. For each state the costs of the open and closed labels are kept, and a new label is dropped if its costs
+// are weakly dominated by one of them. Labels of which the f is weakly dominated by the costs of a solution are
+// dropped as well, so of the solutions with equal costs only the first one is found.
+func namoaStar(ss *solver) []Result {
+	if ss.key == nil {
+		panic("SolvePareto requires the Key of the solver")
+	}
+	for _, rootState := range ss.rootStates {
+		if _, ok := rootState.(MultiObjectiveState); !ok {
+			panic("SolvePareto requires states implementing MultiObjectiveState")
+		}
+	}
+	context := ss.newContext()
+	labels := make(map[interface{}][]*label) // the open and closed labels of each state
+	var open openLabels
+	var solutions []*label
+	visited, expanded, seq := 0, 0, 0
+
+	add := func(parent *label, state State, depth int) {
+		mo := state.(MultiObjectiveState)
+		g := mo.Costs(context)
+		h := mo.Heuristics(context)
+		f := make([]float64, len(g))
+		for i := range g {
+			f[i] = g[i] + h[i]
+		}
+		// weak dominance, since a solution with equal costs would not extend the Pareto front
+		for _, s := range solutions {
+			if weaklyDominates(s.g, f) {
+				return
+			}
+		}
+		key := ss.key(canonicalState(ss.canonical, state))
+		existing := labels[key]
+		for _, l := range existing {
+			if weaklyDominates(l.g, g) {
+				return
+			}
+		}
+		// remove the labels that are dominated by the new label
+		kept := existing[:0]
+		for _, l := range existing {
+			if weaklyDominates(g, l.g) {
+				if l.index >= 0 {
+					heap.Remove(&open, l.index)
+				}
+			} else {
+				kept = append(kept, l)
+			}
+		}
+		l := &label{parent, state, g, f, depth, seq, -1}
+		seq++
+		labels[key] = append(kept, l)
+		heap.Push(&open, l)
+	}
+
+	for _, rootState := range ss.rootStates {
+		add(nil, rootState, 0)
+	}
+	for open.Len() > 0 {
+		l := heap.Pop(&open).(*label)
+		visited++
+		dominated := false
+		for _, s := range solutions {
+			if weaklyDominates(s.g, l.f) {
+				dominated = true
+				break
+			}
+		}
+		if dominated {
+			continue
+		}
+		if l.state.IsGoal(context) {
+			solutions = append(solutions, l)
+			continue
+		}
+		if l.depth >= ss.maxDepth {
+			continue
+		}
+		expanded++
+		for _, child := range l.state.Expand(context) {
+			add(l, child, l.depth+1)
+		}
+	}
+
+	results := make([]Result, len(solutions))
+	for i, s := range solutions {
+		var path []State
+		for l := s; l != nil; l = l.parent {
+			path = append([]State{l.state}, path...)
+		}
+		results[i] = Result{path, s.g[0], visited, expanded, 0, s.g, nil}
+	}
+	return results
+}
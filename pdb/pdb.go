@@ -0,0 +1,216 @@
+// Package pdb builds pattern databases to be used as heuristic for the solve package.
+//
+// A pattern database stores the exact costs to reach the goal for every state in an abstraction of the problem,
+// for example the sliding puzzle where only the positions of some of the tiles are distinguished. Since the costs
+// in the abstraction never exceed the costs in the actual problem, the pattern database is an admissible heuristic.
+//
+// The database is built with a backward breadth-first search from the abstract goal states, for which the pattern
+// needs to provide the predecessors of the abstract states. The values are stored in 4 bits per state when
+// possible and in a byte per state otherwise.
+package pdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/bertbaron/solve"
+)
+
+// Pattern describes the abstraction of the problem. The abstract states can be of any type.
+type Pattern interface {
+	// Number of abstract states
+	Size() int
+
+	// Returns the unique index of the abstract state, in the range [0, Size())
+	Rank(abstract interface{}) int
+
+	// Returns the abstract state with the given index, the inverse of Rank
+	Unrank(rank int) interface{}
+
+	// Maps a state of the problem to its abstract state
+	Abstract(state solve.State) interface{}
+
+	// Returns the abstract states from which the given abstract state can be reached in a single step, with the
+	// costs of that step. Costs of 0 are allowed, for example for the moves that are not counted in additive
+	// pattern databases.
+	Predecessors(abstract interface{}) []Predecessor
+}
+
+// Predecessor is an abstract state from which another abstract state can be reached in a single step
+type Predecessor struct {
+	State interface{}
+	Cost  int
+}
+
+const (
+	unreachable = 255
+	maxValue    = unreachable - 1
+	nibbleMax   = 15 // unreachable in the nibble representation
+)
+
+// PDB is a pattern database
+type PDB struct {
+	pattern Pattern
+	size    int
+	nibbles bool   // 2 values per byte if true
+	data    []byte // 255 or 15 for unreachable states
+}
+
+// Build builds the pattern database with a backward search from the given abstract goal states. Costs larger than
+// 254 are stored as 254.
+func Build(pattern Pattern, goals ...interface{}) *PDB {
+	size := pattern.Size()
+	values := make([]byte, size)
+	for i := range values {
+		values[i] = unreachable
+	}
+	// bucket queue on the costs, each bucket contains the ranks of the states with those costs
+	var buckets [][]int32
+	push := func(rank int, value int) {
+		for value >= len(buckets) {
+			buckets = append(buckets, nil)
+		}
+		buckets[value] = append(buckets[value], int32(rank))
+	}
+	for _, goal := range goals {
+		rank := pattern.Rank(goal)
+		values[rank] = 0
+		push(rank, 0)
+	}
+	max := 0
+	for value := 0; value < len(buckets); value++ {
+		// the bucket may grow during the iteration because of steps without costs
+		for i := 0; i < len(buckets[value]); i++ {
+			rank := int(buckets[value][i])
+			if int(values[rank]) != value {
+				// a cheaper path was found after this state was added
+				continue
+			}
+			if value > max {
+				max = value
+			}
+			for _, predecessor := range pattern.Predecessors(pattern.Unrank(rank)) {
+				r := pattern.Rank(predecessor.State)
+				v := value + predecessor.Cost
+				if v > maxValue {
+					v = maxValue
+				}
+				if v < int(values[r]) {
+					values[r] = byte(v)
+					push(r, v)
+				}
+			}
+		}
+		buckets[value] = nil
+	}
+	pdb := &PDB{pattern, size, false, values}
+	if max < nibbleMax {
+		pdb.compact()
+	}
+	return pdb
+}
+
+// Stores the values in nibbles, only possible if all values are smaller than 15
+func (p *PDB) compact() {
+	data := make([]byte, (p.size+1)/2)
+	for i, value := range p.data {
+		if value == unreachable {
+			value = nibbleMax
+		}
+		data[i/2] |= value << (uint(i%2) * 4)
+	}
+	p.data = data
+	p.nibbles = true
+}
+
+func (p *PDB) value(rank int) (int, bool) {
+	if p.nibbles {
+		value := int(p.data[rank/2]>>(uint(rank%2)*4)) & 0xf
+		return value, value != nibbleMax
+	}
+	value := int(p.data[rank])
+	return value, value != unreachable
+}
+
+// Value returns the costs to reach the goal from the abstract state, and false if the goal can not be reached
+func (p *PDB) Value(abstract interface{}) (int, bool) {
+	return p.value(p.pattern.Rank(abstract))
+}
+
+// Heuristic returns the costs to reach the goal from the abstraction of the state, which is infinite if the
+// goal can not be reached. Is a solve.HeuristicFunc, so it can be passed to Solver.Heuristic and combined with the
+// other heuristics, for example with solve.Max. Can also be used to implement State.Heuristic.
+func (p *PDB) Heuristic(state solve.State, ctx solve.Context) float64 {
+	value, ok := p.Value(p.pattern.Abstract(state))
+	if !ok {
+		return math.Inf(1)
+	}
+	return float64(value)
+}
+
+// Additive returns a heuristic that is the sum of the values of the pattern databases. This is only admissible if
+// the pattern databases are disjoint, meaning that each step of the problem is counted in at most one of the
+// abstractions.
+func Additive(pdbs ...*PDB) solve.HeuristicFunc {
+	heuristics := make([]solve.HeuristicFunc, len(pdbs))
+	for i, p := range pdbs {
+		heuristics[i] = p.Heuristic
+	}
+	return solve.Sum(heuristics...)
+}
+
+var magic = [4]byte{'P', 'D', 'B', '1'}
+
+// Save writes the pattern database to w
+func (p *PDB) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var header [13]byte
+	copy(header[:], magic[:])
+	if p.nibbles {
+		header[4] = 4
+	} else {
+		header[4] = 8
+	}
+	binary.LittleEndian.PutUint64(header[5:], uint64(p.size))
+	if _, err := bw.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := bw.Write(p.data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Load reads a pattern database that was saved with Save. The pattern must be the same as the one the database was
+// built with.
+func Load(r io.Reader, pattern Pattern) (*PDB, error) {
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte{header[0], header[1], header[2], header[3]} != magic {
+		return nil, errors.New("not a pattern database")
+	}
+	size := int(binary.LittleEndian.Uint64(header[5:]))
+	if size != pattern.Size() {
+		return nil, fmt.Errorf("pattern database has %d states, but the pattern has %d", size, pattern.Size())
+	}
+	p := &PDB{pattern: pattern, size: size}
+	switch header[4] {
+	case 4:
+		p.nibbles = true
+		p.data = make([]byte, (size+1)/2)
+	case 8:
+		p.data = make([]byte, size)
+	default:
+		return nil, fmt.Errorf("invalid number of bits per state: %d", header[4])
+	}
+	if _, err := io.ReadFull(r, p.data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
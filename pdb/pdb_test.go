@@ -0,0 +1,237 @@
+package pdb
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/bertbaron/solve"
+)
+
+// Vector of 4 elements which is sorted by swapping adjacent elements
+type vector [4]byte
+
+func (v vector) Cost(ctx solve.Context) float64         { return 0 }
+func (v vector) IsGoal(ctx solve.Context) bool          { return v == vector{0, 1, 2, 3} }
+func (v vector) Expand(ctx solve.Context) []solve.State { return nil }
+func (v vector) Heuristic(ctx solve.Context) float64    { return 0 }
+
+// Encodes each element in 2 bits, so also vectors that are no permutation can be ranked
+type vectorPattern struct {
+	cost int
+	// maps the elements to their abstraction, for example to make some elements indistinguishable
+	abstract func(element byte) byte
+}
+
+func (p vectorPattern) Size() int { return 256 }
+
+func (p vectorPattern) Rank(abstract interface{}) int {
+	v := abstract.(vector)
+	return int(v[0]) | int(v[1])<<2 | int(v[2])<<4 | int(v[3])<<6
+}
+
+func (p vectorPattern) Unrank(rank int) interface{} {
+	return vector{byte(rank & 3), byte(rank >> 2 & 3), byte(rank >> 4 & 3), byte(rank >> 6 & 3)}
+}
+
+func (p vectorPattern) Abstract(state solve.State) interface{} {
+	v := state.(vector)
+	for i := range v {
+		v[i] = p.abstract(v[i])
+	}
+	return v
+}
+
+func (p vectorPattern) Predecessors(abstract interface{}) []Predecessor {
+	v := abstract.(vector)
+	var predecessors []Predecessor
+	for i := 0; i < len(v)-1; i++ {
+		if v[i] == v[i+1] {
+			continue
+		}
+		w := v
+		w[i], w[i+1] = w[i+1], w[i]
+		predecessors = append(predecessors, Predecessor{w, p.cost})
+	}
+	return predecessors
+}
+
+func identity(element byte) byte { return element }
+
+func inversions(v vector) int {
+	count := 0
+	for i := range v {
+		for j := i + 1; j < len(v); j++ {
+			if v[i] > v[j] {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func permutations() []vector {
+	var result []vector
+	var permute func(v vector, i int)
+	permute = func(v vector, i int) {
+		if i == len(v) {
+			result = append(result, v)
+			return
+		}
+		for j := i; j < len(v); j++ {
+			w := v
+			w[i], w[j] = w[j], w[i]
+			permute(w, i+1)
+		}
+	}
+	permute(vector{0, 1, 2, 3}, 0)
+	return result
+}
+
+func TestBuild(t *testing.T) {
+	p := Build(vectorPattern{1, identity}, vector{0, 1, 2, 3})
+	if !p.nibbles {
+		t.Errorf("Expected values to be stored in nibbles")
+	}
+	for _, v := range permutations() {
+		if h := p.Heuristic(v, solve.Context{}); h != float64(inversions(v)) {
+			t.Errorf("Expected %d for %v, but was %v", inversions(v), v, h)
+		}
+	}
+	if value, ok := p.Value(vector{0, 0, 1, 2}); ok {
+		t.Errorf("Expected vector that is no permutation to be unreachable, but was %d", value)
+	}
+	if h := p.Heuristic(vector{3, 3, 3, 3}, solve.Context{}); !math.IsInf(h, 1) {
+		t.Errorf("Expected infinite heuristic for unreachable state, but was %v", h)
+	}
+}
+
+func TestBuildLargeCosts(t *testing.T) {
+	p := Build(vectorPattern{10, identity}, vector{0, 1, 2, 3})
+	if p.nibbles {
+		t.Errorf("Expected values to be stored in bytes")
+	}
+	if h := p.Heuristic(vector{3, 2, 1, 0}, solve.Context{}); h != 60 {
+		t.Errorf("Expected 60, but was %v", h)
+	}
+
+	p = Build(vectorPattern{100, identity}, vector{0, 1, 2, 3})
+	if h := p.Heuristic(vector{3, 2, 1, 0}, solve.Context{}); h != 254 {
+		t.Errorf("Expected costs to be truncated to 254, but was %v", h)
+	}
+}
+
+// Abstractions in which only the elements 0 and 1 or 2 and 3 are distinguished. Swaps of the other elements are
+// counted in the other abstraction, so the pattern databases are disjoint.
+type disjointPattern struct {
+	vectorPattern
+	pattern func(element byte) bool
+}
+
+func (p disjointPattern) Predecessors(abstract interface{}) []Predecessor {
+	v := abstract.(vector)
+	var predecessors []Predecessor
+	for i := 0; i < len(v)-1; i++ {
+		if v[i] == v[i+1] {
+			continue
+		}
+		w := v
+		w[i], w[i+1] = w[i+1], w[i]
+		cost := 0
+		if p.pattern(v[i]) && p.pattern(v[i+1]) {
+			cost = 1
+		}
+		predecessors = append(predecessors, Predecessor{w, cost})
+	}
+	return predecessors
+}
+
+func TestAdditive(t *testing.T) {
+	isLow := func(e byte) bool { return e < 2 }
+	isHigh := func(e byte) bool { return e >= 2 }
+	// elements outside the pattern are mapped to an element that is not in the pattern
+	low := disjointPattern{vectorPattern{1, func(e byte) byte {
+		if isLow(e) {
+			return e
+		}
+		return 3
+	}}, isLow}
+	high := disjointPattern{vectorPattern{1, func(e byte) byte {
+		if isHigh(e) {
+			return e
+		}
+		return 0
+	}}, isHigh}
+	lowPDB := Build(low, vector{0, 1, 3, 3})
+	highPDB := Build(high, vector{0, 0, 2, 3})
+	sum := Additive(lowPDB, highPDB)
+	max := solve.Max(lowPDB.Heuristic, highPDB.Heuristic)
+
+	if h := sum(vector{1, 0, 3, 2}, solve.Context{}); h != 2 {
+		t.Errorf("Expected 2, but was %v", h)
+	}
+	if h := max(vector{1, 0, 3, 2}, solve.Context{}); h != 1 {
+		t.Errorf("Expected 1, but was %v", h)
+	}
+	for _, v := range permutations() {
+		if h := sum(v, solve.Context{}); h > float64(inversions(v)) {
+			t.Errorf("Expected admissible heuristic for %v, but %v > %d", v, h, inversions(v))
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	for _, cost := range []int{1, 10} {
+		p := Build(vectorPattern{cost, identity}, vector{0, 1, 2, 3})
+		var buf bytes.Buffer
+		if err := p.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(&buf, vectorPattern{cost, identity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rank := 0; rank < p.size; rank++ {
+			expectedValue, expectedOk := p.value(rank)
+			value, ok := loaded.value(rank)
+			if value != expectedValue || ok != expectedOk {
+				t.Errorf("Expected (%d, %v) for %d, but was (%d, %v)", expectedValue, expectedOk, rank, value, ok)
+			}
+		}
+	}
+
+	if _, err := Load(bytes.NewReader([]byte("no pattern database")), vectorPattern{1, identity}); err == nil {
+		t.Errorf("Expected error for invalid input")
+	}
+}
+
+// Vector that uses a pattern database as heuristic
+type pdbVector struct {
+	v   vector
+	pdb *PDB
+}
+
+func (s pdbVector) Cost(ctx solve.Context) float64         { return 0 }
+func (s pdbVector) IsGoal(ctx solve.Context) bool          { return s.v.IsGoal(ctx) }
+func (s pdbVector) Expand(ctx solve.Context) []solve.State { return nil }
+func (s pdbVector) ExpandSteps(ctx solve.Context) []solve.Step {
+	var steps []solve.Step
+	for i := 0; i < len(s.v)-1; i++ {
+		w := s.v
+		w[i], w[i+1] = w[i+1], w[i]
+		steps = append(steps, solve.Step{State: pdbVector{w, s.pdb}, Cost: 1})
+	}
+	return steps
+}
+func (s pdbVector) Heuristic(ctx solve.Context) float64 { return s.pdb.Heuristic(s.v, ctx) }
+
+func TestSolveWithPDB(t *testing.T) {
+	p := Build(vectorPattern{1, identity}, vector{0, 1, 2, 3})
+	result := solve.NewSolver(pdbVector{vector{3, 1, 2, 0}, p}).Solve()
+	if result.Cost != 5 {
+		t.Errorf("Expected costs 5, but was %v", result.Cost)
+	}
+	if len(result.Solution) != 6 {
+		t.Errorf("Expected solution of 6 states, but was %v", result.Solution)
+	}
+}
@@ -0,0 +1,161 @@
+package solve
+
+import (
+	"math"
+)
+
+// PartialExpansionState can be implemented by states to generate only the children that are needed by
+// PartialExpansionAstar, instead of generating all children each time the state is expanded again.
+//
+// The delta of a child is the value (costs plus heuristic) of the child minus the value of this state. ExpandBand
+// returns the children with a delta in [low, high], and the smallest delta greater than high of the other
+// children, or math.Inf(1) if there are none. The deltas must be computed with the heuristic that is used by the
+// solver, see Solver.Heuristic. Not used for states that implement StepState.
+type PartialExpansionState interface {
+	State
+
+	// Returns the children with a delta in [low, high] and the smallest delta of the other children above high
+	ExpandBand(ctx Context, low float64, high float64) (children []State, next float64)
+}
+
+type peaStar struct {
+	search
+	rootStates []State
+	partial    map[*node]*peaExpansion // the nodes that are partially expanded, the queue has the next value
+}
+
+// Progress of the partial expansion of a node
+type peaExpansion struct {
+	f      float64   // the value of the node when it is fully expanded
+	values []float64 // the values of the children, in the order in which they are generated
+	delta  float64   // the next delta for ExpandBand
+}
+
+func newPEAStar(rootStates []State, template search) *peaStar {
+	return &peaStar{template, rootStates, make(map[*node]*peaExpansion)}
+}
+
+func (p *peaStar) run() result {
+	if p.rootStates != nil {
+		p.addRoots(p.rootStates)
+		p.rootStates = nil
+	}
+	for {
+		n := p.queue.Take()
+		if n == nil {
+			return result{nil, p.contour, p.cutoff, p.visited, p.expanded, nil, nil}
+		}
+		p.visited++
+		if _, partial := p.partial[n]; !partial {
+			if p.constraint.onVisit(n) {
+				continue
+			}
+			if n.state.IsGoal(p.context) {
+				next := func() result {
+					p.expand(n)
+					return p.run()
+				}
+				return result{n, p.contour, p.cutoff, p.visited, p.expanded, &next, nil}
+			}
+		}
+		p.expand(n)
+	}
+}
+
+// Adds the children with the current value of the node, and adds the node again with the smallest value of the
+// remaining children. The values of the children are computed when the node is expanded for the first time, and
+// the children with those values are selected again when the node is expanded again.
+func (p *peaStar) expand(n *node) {
+	e, partial := p.partial[n]
+	if !partial {
+		e = &peaExpansion{n.value, nil, 0}
+	}
+	current := n.value
+	n.value = e.f // the children inherit the original value when it is larger than their own
+	depth := n.depth + 1
+	if depth > p.maxDepth {
+		p.cutoff = true
+		delete(p.partial, n)
+		return
+	}
+	var next float64
+	if ps, ok := n.state.(PartialExpansionState); ok && !isStepState(n.state) {
+		next = p.expandBand(n, ps, e, partial)
+	} else {
+		next = p.expandChildren(n, e, partial, current)
+	}
+	if math.IsInf(next, 1) {
+		// all children are added
+		delete(p.partial, n)
+		return
+	}
+	if next > p.limit {
+		p.contour = math.Min(p.contour, next)
+		delete(p.partial, n)
+		return
+	}
+	p.partial[n] = e
+	n.value = next
+	p.queue.Add(n)
+}
+
+// Adds the children of which the value equals the current value of the node, returns the smallest value of the
+// other children that have a larger value
+func (p *peaStar) expandChildren(n *node, e *peaExpansion, partial bool, current float64) float64 {
+	next := math.Inf(1)
+	i := 0
+	eachChild(n.state, p.context, func(child State, stepCost float64) bool {
+		cost := n.cost + stepCost
+		if math.IsNaN(stepCost) {
+			cost = child.Cost(p.context)
+		}
+		if !partial {
+			e.values = append(e.values, math.Max(e.f, cost+p.heuristic(child, cost, n.depth+1)))
+		}
+		value := e.values[i]
+		i++
+		if value > current {
+			next = math.Min(next, value)
+		} else if value == current {
+			p.addChild(n, child, cost, value, n.depth+1)
+		}
+		return true
+	})
+	return next
+}
+
+// Adds the children in the band of the current delta, returns the value of the next band
+func (p *peaStar) expandBand(n *node, ps PartialExpansionState, e *peaExpansion, partial bool) float64 {
+	low := e.delta
+	if !partial {
+		low = math.Inf(-1) // the children with a smaller value inherit the value of the node
+	}
+	children, nextDelta := ps.ExpandBand(p.context, low, e.delta)
+	for _, child := range children {
+		cost := child.Cost(p.context)
+		p.addChild(n, child, cost, math.Max(e.f, cost+p.heuristic(child, cost, n.depth+1)), n.depth+1)
+	}
+	e.delta = nextDelta
+	return e.f + nextDelta
+}
+
+// Adds the child for which the value is already computed to the queue, unless it is dropped by the constraint
+func (p *peaStar) addChild(parent *node, child State, cost float64, value float64, depth int) {
+	childNode := p.nodes.newNode(parent, child, cost, value, depth)
+	if p.constraint.onExpand(childNode) {
+		p.nodes.release()
+		return
+	}
+	if childNode.value > p.limit {
+		p.contour = math.Min(p.contour, childNode.value)
+		p.nodes.release()
+		return
+	}
+	p.queue.Add(childNode)
+	p.expanded++
+}
+
+func isStepState(state State) bool {
+	_, ok := state.(StepState)
+	return ok
+}
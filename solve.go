@@ -9,6 +9,54 @@ import (
 // during the search.
 type Context struct {
 	Custom interface{}
+
+	eval      *evaluation
+	heuristic HeuristicFunc // the heuristic of the solver, nil to use the heuristic of the states
+}
+
+// The node of which the heuristic is computed
+type evaluation struct {
+	bound float64
+	cost  float64
+	depth int
+}
+
+// Bound returns the bound on the value (costs plus heuristic) of the states that are expanded, which is the contour
+// of the current iteration for IDA* and the limit for the other algorithms. Since a state is not expanded anyway
+// when its value exceeds the bound, State.Heuristic can stop refining its estimate as soon as it exceeds
+// Bound() - PathCost().
+func (ctx Context) Bound() float64 {
+	if ctx.eval == nil {
+		return math.Inf(1)
+	}
+	return ctx.eval.bound
+}
+
+// PathCost returns the costs of the state of which the heuristic is computed. Only valid in State.Heuristic.
+func (ctx Context) PathCost() float64 {
+	if ctx.eval == nil {
+		return 0
+	}
+	return ctx.eval.cost
+}
+
+// Depth returns the depth in the search tree of the state of which the heuristic is computed, where the root states
+// have depth 0. Only valid in State.Heuristic.
+func (ctx Context) Depth() int {
+	if ctx.eval == nil {
+		return 0
+	}
+	return ctx.eval.depth
+}
+
+func (ctx Context) evaluate(state State, cost float64, depth int, bound float64) float64 {
+	if ctx.eval != nil {
+		*ctx.eval = evaluation{bound, cost, depth}
+	}
+	if ctx.heuristic != nil {
+		return ctx.heuristic(state, ctx)
+	}
+	return state.Heuristic(ctx)
 }
 
 // The State representing a state in the search tree
@@ -31,17 +79,72 @@ type State interface {
 	Heuristic(ctx Context) float64
 }
 
+// Step is a transition from a state to one of its child states together with the costs
+// of that single step
+type Step struct {
+	State State
+	Cost  float64
+}
+
+// StepState is an alternative way to model the problem where the costs are provided per
+// step instead of cumulative by State.Cost.
+//
+// When a state implements this interface the solver uses ExpandSteps instead of Expand
+// and keeps track of the accumulated costs itself, so the states don't need to carry their
+// costs. States that only differ in the path that led to them can therefore be equal, which
+// makes them cheaper to hash and compare in for example a CPMap. Cost and Expand are not
+// called by the solver for such states, except Cost when the state is returned by the Expand
+// of a parent that does not implement StepState, since the costs of the step are then not
+// known. The costs of the root state are 0.
+type StepState interface {
+	State
+
+	// Expands this state in zero or more steps to child states
+	ExpandSteps(ctx Context) []Step
+}
+
+// Successors iterates over the child states of a LazyState
+type Successors interface {
+	// Returns the next child state, or false if there are no more child states
+	Next() (State, bool)
+}
+
+// LazyState can be implemented by states to generate the child states one at a time. DepthFirst, IDAstar and
+// IterativeDeepening then only generate the next child state when the subtrees of the previous ones are searched,
+// which avoids generating the remaining child states when a solution is found or the search is cut off. The child
+// states are searched in the order of the iterator. The other algorithms, and states that implement StepState, use
+// Expand instead.
+type LazyState interface {
+	State
+
+	// Returns an iterator over the child states
+	Successors(ctx Context) Successors
+}
+
 // Result of the search
 type Result struct {
 	// The list of states leading from the root state to the goal state. If no solution
 	// is found this list will be empty
 	Solution []State
 
+	// The costs of the solution, 0 if no solution is found
+	Cost float64
+
 	// Number of nodes visited (dequeued) by the algorithm
 	Visited int
 
 	// Number of nodes expanded (enqueued) by the algorithm
 	Expanded int
+
+	// Estimated probability that a new state is wrongly dropped by a probabilistic constraint, like the
+	// BloomFilterConstraint, at the moment of the result. 0 for other constraints.
+	FalsePositiveRate float64
+
+	// The costs of the solution for each objective, only for the results of SolvePareto
+	Costs []float64
+
+	// The error that ended the search, like a disk error of ExternalBreadthFirst. nil if the search did not fail.
+	Err error
 }
 
 // Solved returns true if the result yields a solution
@@ -57,72 +160,315 @@ func (r Result) GoalState() State {
 type node struct {
 	parent *node
 	state  State
+	cost   float64
 	value  float64
+	depth  int
+	cursor *cursor // not nil while the node is lazily expanded
+	id     int     // the number of nodes in the arena up to and including this node, 0 if not in an arena
+}
+
+// Progress of the lazy expansion of a node
+type cursor struct {
+	successors Successors
+	min        float64
+	complete   bool
+	learn      bool // if the constraint should learn from the node when all children are expanded
+}
+
+// Nodes are allocated in slabs to reduce the number of allocations and thereby the pressure on the garbage
+// collector. Since the slabs are never moved or resized, the nodes can still refer to their parent by pointer, so
+// the constraints can walk the ancestors of a node without access to the arena.
+const slabSize = 1024
+
+type nodeArena struct {
+	slabs [][]node
+	slab  int // index of the current slab
+	next  int // index of the next free node in the current slab
+}
+
+func (a *nodeArena) newNode(parent *node, state State, cost float64, value float64, depth int) *node {
+	if a.slab == len(a.slabs) {
+		a.slabs = append(a.slabs, make([]node, slabSize))
+	}
+	n := &a.slabs[a.slab][a.next]
+	a.next++
+	*n = node{parent, state, cost, value, depth, nil, a.slab*slabSize + a.next}
+	if a.next == slabSize {
+		a.slab++
+		a.next = 0
+	}
+	return n
+}
+
+// Releases the last allocated node, which should not be referenced anymore
+func (a *nodeArena) release() {
+	if a.next == 0 {
+		a.slab--
+		a.next = slabSize
+	}
+	a.next--
+	a.slabs[a.slab][a.next] = node{}
+}
+
+// Releases all nodes that are allocated after the node, which should not be referenced anymore. For depth-first
+// searches this is the case for all nodes that are allocated after the node that is taken from the stack, since
+// their subtrees are completely searched, so only the nodes on the current path and their siblings are kept.
+func (a *nodeArena) releaseAfter(n *node) {
+	for a.slab*slabSize+a.next > n.id {
+		a.release()
+	}
+}
+
+// Makes all nodes available again, which is only safe if none of the nodes are referenced anymore. Typically used
+// at the start of a new iteration of an iterative algorithm. The slabs are cleared to release the states.
+func (a *nodeArena) reset() {
+	for i := 0; i <= a.slab && i < len(a.slabs); i++ {
+		slab := a.slabs[i]
+		for j := range slab {
+			slab[j] = node{}
+		}
+	}
+	a.slab = 0
+	a.next = 0
+}
+
+func rootNode(state State, context Context) *node {
+	cost := 0.0
+	if _, ok := state.(StepState); !ok {
+		cost = state.Cost(context)
+	}
+	return &node{nil, state, cost, cost + context.evaluate(state, cost, 0, math.Inf(1)), 0, nil, 0}
 }
 
 type result struct {
 	node     *node
 	contour  float64
+	cutoff   bool
 	visited  int
 	expanded int
 
 	next *func() result
+	err  error
 }
 
-func expand(n *node, queue strategy, expanded int, constr iconstraint, limit float64, contour float64, context Context) (newExpanded int, newContour float64) {
-	newExpanded = expanded
-	newContour = contour
-	for _, child := range n.state.Expand(context) {
-		childNode := &node{n, child, math.Max(n.value, child.Cost(context)+child.Heuristic(context))}
-		if constr.onExpand(childNode) {
-			continue
+// A single search through the tree, either a complete search or a single iteration of an iterative algorithm
+type search struct {
+	queue      strategy
+	nodes      *nodeArena
+	constraint iconstraint
+	cache      *heuristicCache // nil if the heuristic is not cached
+	context    Context
+	limit      float64 // nodes with a greater value are not expanded
+	maxDepth   int     // nodes with a greater depth are not expanded
+	ubound     float64 // only goals with a greater value are returned
+	minDepth   int     // only goals with a greater depth are returned
+	depthFirst bool    // true for a depth-first queue, for which LazyStates are expanded one child at a time
+
+	visited  int
+	expanded int
+	contour  float64 // smallest value of the nodes that are not expanded because of the limit
+	cutoff   bool    // true if nodes are not expanded because of the maximum depth
+}
+
+func newSearch(queue strategy, constraint iconstraint, cache *heuristicCache, context Context, limit float64, maxDepth int) search {
+	return search{queue, &nodeArena{}, constraint, cache, context, limit, maxDepth, -1.0, -1, false, 0, 0, math.Inf(1), false}
+}
+
+func (s *search) heuristic(state State, cost float64, depth int) float64 {
+	if s.cache == nil {
+		return s.context.evaluate(state, cost, depth, s.limit)
+	}
+	key := s.cache.key(state)
+	if h, ok := s.cache.get(key); ok {
+		return h
+	}
+	h := s.context.evaluate(state, cost, depth, s.limit)
+	s.cache.put(key, h)
+	return h
+}
+
+// Adds the child to the queue unless it is dropped. Returns the value of the child, or NaN if the child is dropped
+// because of the maximum depth.
+//
+// The heuristic is only computed when the child is not dropped by the constraint, which therefore sees the value
+// without the heuristic of the child.
+func (s *search) add(parent *node, child State, cost float64) float64 {
+	depth := parent.depth + 1
+	if depth > s.maxDepth {
+		s.cutoff = true
+		return math.NaN()
+	}
+	if d, ok := s.queue.(duplicateDropper); ok && d.isDuplicate(child, cost) {
+		return math.Max(parent.value, cost)
+	}
+	childNode := s.nodes.newNode(parent, child, cost, math.Max(parent.value, cost), depth)
+	if s.constraint.onExpand(childNode) {
+		value := childNode.value
+		s.nodes.release()
+		return value
+	}
+	// the value may be raised by the constraint
+	childNode.value = math.Max(childNode.value, cost+s.heuristic(child, cost, depth))
+	value := childNode.value
+	if value > s.limit {
+		s.contour = math.Min(s.contour, value)
+		s.nodes.release()
+		return value
+	}
+	s.queue.Add(childNode)
+	s.expanded++
+	return value
+}
+
+// Expands the node, returning the minimum value of the children and true if none of the children is dropped
+// because of the maximum depth
+func (s *search) expand(n *node) (min float64, complete bool) {
+	min, complete = math.Inf(1), true
+	add := func(child State, cost float64) {
+		value := s.add(n, child, cost)
+		if math.IsNaN(value) {
+			complete = false
+		} else {
+			min = math.Min(min, value)
 		}
-		if childNode.value > limit {
-			newContour = math.Min(newContour, childNode.value)
-			continue
+	}
+	if ss, ok := n.state.(StepState); ok {
+		for _, step := range ss.ExpandSteps(s.context) {
+			add(step.State, n.cost+step.Cost)
 		}
-		queue.Add(childNode)
-		newExpanded++
+		return
+	}
+	for _, child := range n.state.Expand(s.context) {
+		add(child, child.Cost(s.context))
 	}
 	return
 }
 
-func generalSearch(queue strategy, visited int, expanded int, constr iconstraint, ubound float64, limit float64, contour float64, context Context) result {
+// Starts the lazy expansion of the node if possible. The node is put back on the stack and each time it is taken the
+// next child is added on top of it.
+func (s *search) expandLazily(n *node, learn bool) bool {
+	if !s.depthFirst {
+		return false
+	}
+	ls, ok := n.state.(LazyState)
+	if !ok {
+		return false
+	}
+	if _, ok := n.state.(StepState); ok {
+		return false
+	}
+	n.cursor = &cursor{ls.Successors(s.context), math.Inf(1), true, learn}
+	s.queue.Add(n)
+	return true
+}
+
+// Adds the next child of the lazily expanded node
+func (s *search) next(n *node) {
+	c := n.cursor
+	child, ok := c.successors.Next()
+	if !ok {
+		n.cursor = nil
+		if l, ok := s.constraint.(learningConstraint); ok && c.learn && c.complete {
+			l.onExpanded(n, c.min)
+		}
+		return
+	}
+	s.queue.Add(n)
+	value := s.add(n, child, child.Cost(s.context))
+	if math.IsNaN(value) {
+		c.complete = false
+	} else {
+		c.min = math.Min(c.min, value)
+	}
+}
+
+func (s *search) run() result {
 	for {
-		n := queue.Take()
+		n := s.queue.Take()
 		if n == nil {
-			return result{nil, contour, visited, expanded, nil}
+			return result{nil, s.contour, s.cutoff, s.visited, s.expanded, nil, nil}
+		}
+		if s.depthFirst {
+			s.nodes.releaseAfter(n)
+		}
+		if n.cursor != nil {
+			s.next(n)
+			continue
 		}
-		visited++
-		if constr.onVisit(n) {
+		s.visited++
+		if s.constraint.onVisit(n) {
 			continue
 		}
-		if n.state.IsGoal(context) && n.value > ubound {
+		if n.state.IsGoal(s.context) && n.value > s.ubound && n.depth > s.minDepth {
 			next := func() result {
-				expanded, contour = expand(n, queue, expanded, constr, limit, contour, context)
-				return generalSearch(queue, visited, expanded, constr, ubound, limit, contour, context)
+				if !s.expandLazily(n, false) {
+					s.expand(n)
+				}
+				return s.run()
 			}
-			return result{n, contour, visited, expanded, &next}
+			return result{n, s.contour, s.cutoff, s.visited, s.expanded, &next, nil}
+		}
+		if s.expandLazily(n, true) {
+			continue
+		}
+		min, complete := s.expand(n)
+		if l, ok := s.constraint.(learningConstraint); ok && complete {
+			l.onExpanded(n, min)
+		}
+	}
+}
+
+// Calls fn for each child of the state with the costs of the step to the child, or NaN if the state provides
+// cumulative costs
+func eachChild(state State, context Context, fn func(child State, stepCost float64) bool) {
+	if ss, ok := state.(StepState); ok {
+		for _, step := range ss.ExpandSteps(context) {
+			if !fn(step.State, step.Cost) {
+				return
+			}
+		}
+		return
+	}
+	for _, child := range state.Expand(context) {
+		if !fn(child, math.NaN()) {
+			return
 		}
-		expanded, contour = expand(n, queue, expanded, constr, limit, contour, context)
 	}
 }
 
-func startGeneralSearch(queue strategy, constr iconstraint, limit float64, context Context) result {
-	return generalSearch(queue, 0, 0, constr, -1.0, limit, math.Inf(1), context)
+func (s *search) addRoots(rootStates []State) {
+	for _, rootState := range rootStates {
+		s.queue.Add(rootNode(rootState, s.context))
+	}
 }
 
-func idaStar(rootState State, constraint iconstraint, contour float64, ubound float64, limit float64, context Context, nextfn *func() result) result {
-	visited := 0
-	expanded := 0
+// Wraps the continuation of the result of an iteration in the given continuation of the iterative algorithm
+func continueWith(r result, fn func(underlyingNextFn *func() result) result) result {
+	underlyingNextFn := r.next
+	nextFn := func() result {
+		return fn(underlyingNextFn)
+	}
+	r.next = &nextFn
+	return r
+}
+
+// The template provides the settings for each iteration, the limit of the template is the limit of the contour
+func idaStar(rootStates []State, template search, contour float64, ubound float64, visited int, expanded int, nextfn *func() result) result {
 	for true {
 		var lastResult result
 		if nextfn == nil {
 			// start with new iteration
-			s := depthFirst()
-			s.Add(&node{nil, rootState, rootState.Cost(context) + rootState.Heuristic(context)})
-			constraint.reset()
-			lastResult = generalSearch(s, visited, expanded, constraint, ubound, contour, math.Inf(1), context)
+			s := template
+			s.queue = depthFirst()
+			s.depthFirst = true
+			s.limit = contour
+			s.ubound = ubound
+			s.visited = visited
+			s.expanded = expanded
+			s.nodes.reset()
+			s.addRoots(rootStates)
+			s.constraint.reset()
+			lastResult = s.run()
 		} else {
 			// continue previous iteration
 			fn := *nextfn
@@ -131,15 +477,13 @@ func idaStar(rootState State, constraint iconstraint, contour float64, ubound fl
 		}
 		if lastResult.node != nil {
 			// Found a solution
-			underlyingNextFn := lastResult.next
-			nextIdaStarFn := func() result {
-				return idaStar(rootState, constraint, contour, ubound, limit, context, underlyingNextFn)
-			}
-			lastResult.next = &nextIdaStarFn
-			return lastResult
+			c, u := contour, ubound
+			return continueWith(lastResult, func(underlyingNextFn *func() result) result {
+				return idaStar(rootStates, template, c, u, 0, 0, underlyingNextFn)
+			})
 		}
 		lastResult.next = nil
-		if lastResult.contour > limit || math.IsInf(lastResult.contour, 1) || math.IsNaN(lastResult.contour) {
+		if lastResult.contour > template.limit || math.IsInf(lastResult.contour, 1) || math.IsNaN(lastResult.contour) {
 			// no (more) solutions
 			return lastResult
 		}
@@ -151,8 +495,80 @@ func idaStar(rootState State, constraint iconstraint, contour float64, ubound fl
 	panic("Shouldn't be reached")
 }
 
-func startIdaStar(rootState State, constraint iconstraint, limit float64, context Context) result {
-	return idaStar(rootState, constraint, 0.0, -1.0, limit, context, nil)
+// The initial contour is the minimum value of the root states
+func startIdaStar(rootStates []State, template search) result {
+	contour := math.Inf(1)
+	for _, rootState := range rootStates {
+		contour = math.Min(contour, rootNode(rootState, template.context).value)
+	}
+	if contour > template.limit || math.IsInf(contour, 1) || math.IsNaN(contour) {
+		// no solutions
+		return result{nil, contour, false, 0, 0, nil, nil}
+	}
+	return idaStar(rootStates, template, contour, -1.0, 0, 0, nil)
+}
+
+// Like idaStar, but the iterations are bound by the depth instead of the value of the nodes. The maximum depth of
+// the template is the maximum depth of the last iteration.
+func iterativeDeepening(rootStates []State, template search, depth int, visited int, expanded int, nextfn *func() result) result {
+	for true {
+		var lastResult result
+		if nextfn == nil {
+			// start with new iteration
+			s := template
+			s.queue = depthFirst()
+			s.depthFirst = true
+			s.maxDepth = depth
+			s.minDepth = depth - 1
+			s.visited = visited
+			s.expanded = expanded
+			s.nodes.reset()
+			s.addRoots(rootStates)
+			s.constraint.reset()
+			lastResult = s.run()
+		} else {
+			// continue previous iteration
+			fn := *nextfn
+			nextfn = nil
+			lastResult = fn()
+		}
+		if lastResult.node != nil {
+			// Found a solution
+			d := depth
+			return continueWith(lastResult, func(underlyingNextFn *func() result) result {
+				return iterativeDeepening(rootStates, template, d, 0, 0, underlyingNextFn)
+			})
+		}
+		lastResult.next = nil
+		if !lastResult.cutoff || depth >= template.maxDepth {
+			// no (more) solutions
+			return lastResult
+		}
+		visited = lastResult.visited
+		expanded = lastResult.expanded
+		depth++
+	}
+	panic("Shouldn't be reached")
+}
+
+// Depth-first search that lowers the limit of the search after each solution, so that only cheaper solutions are
+// found. The limit of the search is bounded by the queue.
+func branchAndBound(s *search, limit float64, nextfn *func() result) result {
+	var r result
+	if nextfn == nil {
+		r = s.run()
+	} else {
+		r = (*nextfn)()
+	}
+	if r.node == nil {
+		return r
+	}
+	cost := r.node.cost
+	return continueWith(r, func(underlyingNextFn *func() result) result {
+		// only nodes with a value lower than the costs of the incumbent can lead to a better solution
+		s.limit = math.Min(limit, math.Nextafter(cost, math.Inf(-1)))
+		return branchAndBound(s, limit, underlyingNextFn)
+	})
 }
 
 func toSlice(node *node) []State {
@@ -162,54 +578,133 @@ func toSlice(node *node) []State {
 	return append(toSlice(node.parent), node.state)
 }
 
-func toResult(r *result) Result {
-	return Result{toSlice(r.node), r.visited, r.expanded}
+type externalMemory struct {
+	dir        string
+	serializer Serializer
+	memory     int
+}
+
+type cacheSettings struct {
+	key  func(State) interface{}
+	size int
 }
 
 type solver struct {
-	rootState  State
+	rootStates []State
 	algorithm  Algorithm
 	constraint Constraint
 	limit      float64
+	maxDepth   int
+	tieBreaker TieBreaker
+	integer    bool
+	key        func(State) interface{}
+	external   externalMemory
+	cache      cacheSettings
+	canonical  func(State) State
+	heuristic  HeuristicFunc
 	context    interface{}
 
 	started bool
 	result  *result
+	close   func() // releases the resources of the search, nil if there are none
+}
+
+func (ss *solver) toResult(r *result) Result {
+	res := Result{toSlice(r.node), 0, r.visited, r.expanded, 0, nil, r.err}
+	if r.node != nil {
+		res.Cost = r.node.cost
+	}
+	if c, ok := ss.constraint.(probabilisticConstraint); ok {
+		res.FalsePositiveRate = c.falsePositiveRate()
+	}
+	return res
+}
+
+// Returns the context that is passed to the states during a search
+func (ss *solver) newContext() Context {
+	return Context{ss.context, &evaluation{}, ss.heuristic}
+}
+
+func (ss *solver) aStarQueue() strategy {
+	if ss.integer {
+		return integerAStar(ss.tieBreaker.(tieBreaker))
+	}
+	return aStar(ss.tieBreaker.(tieBreaker))
 }
 
 func solve(ss *solver) Result {
 	if ss.started {
 		if ss.result.next == nil {
 			// no more possible solutions
-			return Result{[]State{}, ss.result.visited, ss.result.expanded}
+			return ss.toResult(&result{visited: ss.result.visited, expanded: ss.result.expanded})
 		}
 		nextResult := (*ss.result.next)()
 		ss.result = &nextResult
-		return toResult(ss.result)
+		return ss.toResult(ss.result)
 	}
 	ss.started = true
-	context := Context{ss.context}
+	context := ss.newContext()
 	constraint := ss.constraint.(iconstraint)
-	if ss.algorithm == IDAstar {
-		nextResult := startIdaStar(ss.rootState, constraint, ss.limit, context)
+	if c, ok := constraint.(canonicalizable); ok && ss.canonical != nil {
+		constraint = c.canonicalized(ss.canonical)
+	}
+	var cache *heuristicCache
+	if ss.cache.key != nil {
+		cache = newHeuristicCache(ss.cache.key, ss.cache.size)
+	}
+	template := newSearch(nil, constraint, cache, context, ss.limit, ss.maxDepth)
+	switch ss.algorithm {
+	case IDAstar:
+		nextResult := startIdaStar(ss.rootStates, template)
+		ss.result = &nextResult
+		return ss.toResult(ss.result)
+	case IterativeDeepening:
+		nextResult := iterativeDeepening(ss.rootStates, template, 0, 0, 0, nil)
+		ss.result = &nextResult
+		return ss.toResult(ss.result)
+	case ExternalBreadthFirst:
+		e := ss.external
+		b := newExternalBFS(ss.rootStates, context, e.serializer, e.dir, e.memory, ss.maxDepth, ss.canonical)
+		ss.close = b.close
+		nextResult := b.run()
+		ss.result = &nextResult
+		return ss.toResult(ss.result)
+	case DepthFirstBranchAndBound:
+		s := template
+		s.queue = &boundedDepthFirst{nil, &s.limit}
+		s.depthFirst = true
+		s.addRoots(ss.rootStates)
+		constraint.reset()
+		nextResult := branchAndBound(&s, ss.limit, nil)
 		ss.result = &nextResult
-		return toResult(ss.result)
+		return ss.toResult(ss.result)
+	case PartialExpansionAstar:
+		template.queue = ss.aStarQueue()
+		constraint.reset()
+		nextResult := newPEAStar(ss.rootStates, template).run()
+		ss.result = &nextResult
+		return ss.toResult(ss.result)
 	}
-	var s strategy
+	s := template
 	switch ss.algorithm {
 	case Astar:
-		s = aStar()
+		s.queue = ss.aStarQueue()
 	case DepthFirst:
-		s = depthFirst()
+		s.queue = depthFirst()
+		s.depthFirst = true
 	case BreadthFirst:
-		s = breadthFirst()
+		s.queue = breadthFirst()
+	case FringeSearch:
+		s.queue = fringeQueue()
+	case AstarReopening:
+		s.queue = aStarReopening(ss.key, ss.canonical, ss.tieBreaker.(tieBreaker))
 	}
-	s.Add(&node{nil, ss.rootState, ss.rootState.Cost(context) + ss.rootState.Heuristic(context)})
+	s.addRoots(ss.rootStates)
 
 	constraint.reset()
-	nextResult := startGeneralSearch(s, constraint, ss.limit, context)
+	nextResult := s.run()
 	ss.result = &nextResult
-	return toResult(ss.result)
+	return ss.toResult(ss.result)
 }
 
 // Solver to solve the problem.
@@ -224,6 +719,53 @@ type Solver interface {
 	// to math.Inf(1).
 	Limit(limit float64) Solver
 
+	// The maximum depth to use. States that are deeper in the search tree, where the root states have depth 0,
+	// will not be expanded. Defaults to no maximum. Useful for example for DepthFirst when the costs don't
+	// increase with the depth of the search tree.
+	MaxDepth(depth int) Solver
+
+	// The policy to decide which of the nodes with equal value is taken first by A*, defaults to FIFO
+	TieBreaking(tieBreaker TieBreaker) Solver
+
+	// Declares that the costs and heuristic of all states are integers. This allows A* to use a bucket queue which
+	// is faster than a priority queue, especially when many states have the same value. Buckets are only used for a
+	// window of values near the smallest value in the queue, other values and values that are not integers are kept
+	// in a priority queue. Defaults to false.
+	IntegerCosts(integer bool) Solver
+
+	// Function that identifies the states for the algorithms that detect equal states themselves, like
+	// AstarReopening. The returned keys must be valid map keys and equal for equal states.
+	Key(key func(State) interface{}) Solver
+
+	// Settings for ExternalBreadthFirst. The files are created in a new directory in dir, or in the default directory
+	// for temporary files if dir is empty. The directory is removed when the search is completed. The serializer
+	// identifies the states and memory is the maximum number of states that is kept in memory at once. Use Close
+	// to remove the directory when the search is stopped before it is completed. Disk errors end the search and are
+	// reported in Result.Err.
+	ExternalMemory(dir string, serializer Serializer, memory int) Solver
+
+	// Function that returns the canonical state for a state, which is the same for all states that are symmetric
+	// to each other. When provided, the constraints, AstarReopening and ExternalBreadthFirst detect duplicate
+	// states on the canonical states, which eliminates the symmetric branches from the search tree. The functions
+	// that identify the states, like the samefn of NoLoopConstraint, the CPMap of CheapestPathConstraint, the hash of
+	// TranspositionTableConstraint and BloomFilterConstraint, the Key and the Serializer, then receive the canonical
+	// states. NoLoopConstraint determines the canonical state of each ancestor it compares.
+	//
+	// The solutions still consist of the actual states, except for ExternalBreadthFirst which only stores the
+	// canonical states. Use ConcreteSolution to map such solutions back to the actual states.
+	Canonicalize(canonical func(State) State) Solver
+
+	// Caches the heuristic of at most size states, identified by key, so it is not computed again when the state is
+	// reached again, for example in a later iteration of IDA*. The least recently used values are evicted when the
+	// cache is full. The keys must be valid map keys and equal for equal states. Should not be used with heuristics
+	// that depend on the context, like Lazy and SwitchAtDepth, which depend on the bound and the depth.
+	HeuristicCache(key func(State) interface{}, size int) Solver
+
+	// The heuristic to use instead of State.Heuristic, for example a combination of heuristics like Max or Lazy.
+	// The states are passed to the heuristic as they are, so they can still implement the optional interfaces like
+	// StepState and LazyState. Defaults to nil, which uses State.Heuristic. Not used by SolvePareto.
+	Heuristic(h HeuristicFunc) Solver
+
 	// Custom context which is passed to the methods of the state. Can contain for example precalculated data that
 	// is used to speed up calculations. Be careful with state in the context though.
 	Context(context interface{}) Solver
@@ -237,6 +779,38 @@ type Solver interface {
 
 	// True if the search is completed
 	Completed() bool
+
+	// Stops the search and releases its resources, like the files of ExternalBreadthFirst. Only needed when the
+	// search is not completed, Solve doesn't return any more solutions afterwards.
+	Close()
+
+	// Computes the costs of the cheapest paths from the root states to the states identified by the targets, which
+	// are keys as returned by the Key of the solver, using a uniform-cost search (Dijkstra). Without targets the
+	// costs to all reachable states are returned, otherwise the search stops when the costs to all targets are
+	// known and unreachable targets are not in the map. Requires the Key of the solver. Of the other settings only
+	// the limit, maximum depth, canonical states and context apply. The algorithm, constraint, tie breaking, integer
+	// costs, external memory, heuristic and heuristic cache are not used.
+	Distances(targets ...interface{}) map[interface{}]float64
+
+	// Returns the k cheapest solutions without loops, ordered by their costs, using Yen's algorithm. Unlike SolveAll
+	// the solutions don't contain the same state twice, identified by the Key of the solver, so paths that only
+	// differ by a loop are not returned. A solution is skipped when it has less than diversity states that are not
+	// in one of the previously returned solutions, use 0 to return all solutions. Each state is expanded at most
+	// once per deviation from a previous solution, so the solutions are only the cheapest if the heuristic is
+	// consistent. Requires the Key of the solver. Of the other settings the limit, maximum depth, tie breaking,
+	// integer costs, canonical states, heuristic and context apply. The algorithm, constraint, external memory and
+	// heuristic cache are not used, the searches always use A*.
+	KShortestPaths(k int, diversity int) []Result
+
+	// Returns the Pareto front of the solutions for root states that implement MultiObjectiveState, using NAMOA*.
+	// These are the solutions for which no other solution exists that is at most as expensive for each objective
+	// and cheaper for at least one of them, with a single solution for equal costs. The Costs of the results
+	// contain the costs per objective and Cost the costs of the first objective. The results are ordered by their
+	// costs, compared on the first objective first, and Expanded is the number of paths that are expanded. Requires
+	// the Key of the solver. Of the other settings only the maximum depth, canonical states and context apply. The
+	// algorithm, constraint, limit, tie breaking, integer costs, external memory, heuristic and heuristic cache are
+	// not used, the states provide the heuristics of each objective.
+	SolvePareto() []Result
 }
 
 func (s *solver) Algorithm(algorithm Algorithm) Solver {
@@ -254,6 +828,46 @@ func (s *solver) Limit(limit float64) Solver {
 	return s
 }
 
+func (s *solver) MaxDepth(depth int) Solver {
+	s.maxDepth = depth
+	return s
+}
+
+func (s *solver) TieBreaking(tieBreaker TieBreaker) Solver {
+	s.tieBreaker = tieBreaker
+	return s
+}
+
+func (s *solver) IntegerCosts(integer bool) Solver {
+	s.integer = integer
+	return s
+}
+
+func (s *solver) Key(key func(State) interface{}) Solver {
+	s.key = key
+	return s
+}
+
+func (s *solver) ExternalMemory(dir string, serializer Serializer, memory int) Solver {
+	s.external = externalMemory{dir, serializer, memory}
+	return s
+}
+
+func (s *solver) Canonicalize(canonical func(State) State) Solver {
+	s.canonical = canonical
+	return s
+}
+
+func (s *solver) HeuristicCache(key func(State) interface{}, size int) Solver {
+	s.cache = cacheSettings{key, size}
+	return s
+}
+
+func (s *solver) Heuristic(h HeuristicFunc) Solver {
+	s.heuristic = h
+	return s
+}
+
 func (s *solver) Context(context interface{}) Solver {
 	s.context = context
 	return s
@@ -263,6 +877,18 @@ func (s *solver) Solve() Result {
 	return solve(s)
 }
 
+func (s *solver) Distances(targets ...interface{}) map[interface{}]float64 {
+	return distances(s, targets)
+}
+
+func (s *solver) KShortestPaths(k int, diversity int) []Result {
+	return kShortestPaths(s, k, diversity)
+}
+
+func (s *solver) SolvePareto() []Result {
+	return namoaStar(s)
+}
+
 func (s *solver) SolveAll() <-chan Result {
 	solutions := make(chan Result)
 	go func() {
@@ -278,7 +904,63 @@ func (s *solver) Completed() bool {
 	return s.started && s.result.next == nil
 }
 
+func (s *solver) Close() {
+	if s.close != nil {
+		s.close()
+		s.close = nil
+	}
+	if !s.started {
+		s.started = true
+		s.result = &result{}
+	}
+	s.result.next = nil
+}
+
 // NewSolver creates a new solver
 func NewSolver(rootState State) Solver {
-	return &solver{rootState, Astar, NoConstraint(), math.Inf(1), nil, false, nil}
+	return NewMultiSolver(rootState)
+}
+
+// NewMultiSolver creates a new solver that searches from multiple root states at once. The solutions
+// may start at any of the root states, the cheapest solution is found first by the optimal algorithms.
+func NewMultiSolver(rootStates ...State) Solver {
+	s := &solver{nil, Astar, NoConstraint(), math.Inf(1), math.MaxInt32, FIFO(), false, nil, externalMemory{"", nil, 1 << 20}, cacheSettings{}, nil, nil, nil, false, nil, nil}
+	for _, state := range rootStates {
+		if w, ok := state.(heuristicWrapper); ok {
+			state = w.State
+			s.heuristic = w.h
+		}
+		s.rootStates = append(s.rootStates, state)
+	}
+	return s
+}
+
+// ConcreteSolution maps a solution that consists of canonical states, like the solutions of ExternalBreadthFirst
+// with Solver.Canonicalize, back to the path of actual states that starts with the given root state. For each step
+// the child of the actual state is chosen of which the canonical state is the same as the canonical state of the
+// next state in the solution, where the canonical states are compared with the same function. The context is the
+// custom context of the solver.
+//
+// Returns nil if no such path exists.
+func ConcreteSolution(rootState State, solution []State, canonical func(State) State, same func(a, b State) bool, context interface{}) []State {
+	if len(solution) == 0 || !same(canonical(rootState), canonical(solution[0])) {
+		return nil
+	}
+	ctx := Context{Custom: context}
+	path := []State{rootState}
+	for _, next := range solution[1:] {
+		target := canonical(next)
+		var found State
+		eachChild(path[len(path)-1], ctx, func(child State, stepCost float64) bool {
+			if same(canonical(child), target) {
+				found = child
+			}
+			return found == nil
+		})
+		if found == nil {
+			return nil
+		}
+		path = append(path, found)
+	}
+	return path
 }
@@ -2,9 +2,12 @@ package solve
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 	"unicode"
 )
@@ -88,6 +91,19 @@ var testNoLoopConstraint = NoLoopConstraint(99999, same)
 var testCPMap = make(cpMap)
 var testCheapestPathConstraint = CheapestPathConstraint(&testCPMap)
 
+// for transposition-table-constraint, a new table is needed for each problem
+func hash(s State) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s.(state).node))
+	return h.Sum64()
+}
+
+var testBloomFilterConstraint = BloomFilterConstraint(1024, 3, hash)
+
+func testTranspositionTable() Constraint {
+	return TranspositionTableConstraint(1024, hash, ReplaceByDepth)
+}
+
 type goalCost struct {
 	goal string
 	cost float64
@@ -136,7 +152,8 @@ func testSolve(t *testing.T, graph graph, algorithm Algorithm, constraint Constr
 	actual := solveAll(solver)
 
 	name := fmt.Sprintf("(%v,%v)", algorithm, constraint)
-	if algorithm == Astar || algorithm == BreadthFirst || algorithm == IDAstar {
+	if algorithm == Astar || algorithm == BreadthFirst || algorithm == IDAstar || algorithm == IterativeDeepening ||
+		algorithm == PartialExpansionAstar || algorithm == FringeSearch {
 		if !equalGoalCost(actual, expected) {
 			t.Errorf("%v - Expected %v but found %v", name, expected, actual)
 		}
@@ -164,11 +181,23 @@ func testSolveAllAlgorithms(t *testing.T, graph graph, includeBF bool, expected
 	testSolve(t, graph, IDAstar, testNoReturnConstraint, math.MaxFloat64, expected)
 	testSolve(t, graph, IDAstar, testNoLoopConstraint, math.MaxFloat64, expected)
 	testSolve(t, graph, IDAstar, testCheapestPathConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, IDAstar, testTranspositionTable(), math.MaxFloat64, expected)
+
+	testSolve(t, graph, PartialExpansionAstar, testNoConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, PartialExpansionAstar, testNoReturnConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, PartialExpansionAstar, testNoLoopConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, PartialExpansionAstar, testCheapestPathConstraint, math.MaxFloat64, expected)
+
+	testSolve(t, graph, FringeSearch, testNoConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, FringeSearch, testNoReturnConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, FringeSearch, testNoLoopConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, FringeSearch, testCheapestPathConstraint, math.MaxFloat64, expected)
 
 	testSolve(t, graph, DepthFirst, testNoConstraint, math.MaxFloat64, expected)
 	testSolve(t, graph, DepthFirst, testNoReturnConstraint, math.MaxFloat64, expected)
 	testSolve(t, graph, DepthFirst, testNoLoopConstraint, math.MaxFloat64, expected)
 	testSolve(t, graph, DepthFirst, testCheapestPathConstraint, math.MaxFloat64, expected)
+	testSolve(t, graph, DepthFirst, testBloomFilterConstraint, math.MaxFloat64, expected)
 
 	// BF is only optimal if the length of costs corresonds with the length of the path
 	if includeBF {
@@ -176,6 +205,11 @@ func testSolveAllAlgorithms(t *testing.T, graph graph, includeBF bool, expected
 		testSolve(t, graph, BreadthFirst, testNoReturnConstraint, math.MaxFloat64, expected)
 		testSolve(t, graph, BreadthFirst, testNoLoopConstraint, math.MaxFloat64, expected)
 		testSolve(t, graph, BreadthFirst, testCheapestPathConstraint, math.MaxFloat64, expected)
+
+		testSolve(t, graph, IterativeDeepening, testNoConstraint, math.MaxFloat64, expected)
+		testSolve(t, graph, IterativeDeepening, testNoReturnConstraint, math.MaxFloat64, expected)
+		testSolve(t, graph, IterativeDeepening, testNoLoopConstraint, math.MaxFloat64, expected)
+		testSolve(t, graph, IterativeDeepening, testCheapestPathConstraint, math.MaxFloat64, expected)
 	}
 }
 
@@ -243,6 +277,53 @@ func TestWithSingleStateResult(t *testing.T) {
 	}
 }
 
+func TestMultipleRootStates(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}}
+	g["b"] = []edge{{"B", 4}}
+	g["x"] = []edge{{"C", 2}}
+	expected := []goalCost{{"C", 2}, {"B", 5}}
+	for _, algorithm := range []Algorithm{Astar, IDAstar, BreadthFirst, DepthFirst} {
+		solver := NewMultiSolver(state{g, "a", 0}, state{g, "x", 0}).Algorithm(algorithm)
+		actual := solveAll(solver)
+		if algorithm == DepthFirst {
+			sort.Sort(sortableGoals(actual))
+			sort.Sort(sortableGoals(expected))
+		}
+		if !equalGoalCost(actual, expected) {
+			t.Errorf("%v - Expected %v but found %v", algorithm, expected, actual)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"a", 0}, {"b", 0}}
+	g["b"] = []edge{{"C", 0}}
+
+	actual := solveAll(NewSolver(create(g)).Algorithm(DepthFirst).MaxDepth(2))
+	expected := []goalCost{{"C", 0}}
+	if !equalGoalCost(actual, expected) {
+		t.Errorf("Expected %v but found %v", expected, actual)
+	}
+
+	var depths []int
+	for result := range NewSolver(create(g)).Algorithm(IterativeDeepening).MaxDepth(4).SolveAll() {
+		depths = append(depths, len(result.Solution)-1)
+	}
+	if fmt.Sprint(depths) != "[2 3 4]" {
+		t.Errorf("Expected solutions at depth [2 3 4] but found %v", depths)
+	}
+}
+
+func TestNoRootStates(t *testing.T) {
+	for _, algorithm := range []Algorithm{Astar, IDAstar} {
+		if NewMultiSolver().Algorithm(algorithm).Solve().Solved() {
+			t.Errorf("%v - Expected no solution without root states", algorithm)
+		}
+	}
+}
+
 func testStatistics(t *testing.T, g graph, algorithm Algorithm, constraint Constraint, expExpanded, expVisited int) {
 	name := fmt.Sprintf("(%v,%v)", algorithm, constraint)
 	result := NewSolver(create(g)).
@@ -266,13 +347,427 @@ func TestStatisticsWithDifferentConstraints(t *testing.T) {
 	testStatistics(t, g, BreadthFirst, testCheapestPathConstraint, 4, 5)
 }
 
+// Same graph problem, but with the costs provided per step
+type stepState struct {
+	graph graph
+	node  string
+}
+
+func (s stepState) Cost(ctx Context) float64 {
+	panic("Cost should not be called on a StepState")
+}
+
+func (s stepState) IsGoal(ctx Context) bool {
+	return unicode.IsUpper([]rune(s.node)[0])
+}
+
+func (s stepState) Expand(ctx Context) []State {
+	panic("Expand should not be called on a StepState")
+}
+
+func (s stepState) ExpandSteps(ctx Context) []Step {
+	var steps []Step
+	for _, edge := range s.graph[s.node] {
+		steps = append(steps, Step{stepState{s.graph, edge.target}, edge.cost})
+	}
+	return steps
+}
+
+func (s stepState) Heuristic(ctx Context) float64 {
+	recordEvaluation(ctx)
+	return 0
+}
+
+func TestStepStates(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 8}, {"d", 10}}
+	g["b"] = []edge{{"bb", 1}}
+	g["c"] = []edge{{"cc", 8}}
+	g["d"] = []edge{{"dd", 10}}
+	g["bb"] = []edge{{"B", 200}}
+	g["cc"] = []edge{{"C", 100}}
+	g["dd"] = []edge{{"D", 1}}
+	expected := []goalCost{{"D", 21}, {"C", 116.0}, {"B", 202.0}}
+	for _, algorithm := range []Algorithm{Astar, IDAstar, PartialExpansionAstar} {
+		var actual []goalCost
+		for result := range NewSolver(stepState{g, "a"}).Algorithm(algorithm).SolveAll() {
+			actual = append(actual, goalCost{result.GoalState().(stepState).node, result.Cost})
+		}
+		if !equalGoalCost(actual, expected) {
+			t.Errorf("%v - Expected %v but found %v", algorithm, expected, actual)
+		}
+	}
+}
+
+// Same graph problem, but with the children generated one at a time
+type lazyState struct {
+	state
+	generated *int
+}
+
+type lazySuccessors struct {
+	parent lazyState
+	edges  []edge
+}
+
+func (it *lazySuccessors) Next() (State, bool) {
+	if len(it.edges) == 0 {
+		return nil, false
+	}
+	child := testExpand(it.parent.state, it.edges[0])
+	it.edges = it.edges[1:]
+	*it.parent.generated++
+	return lazyState{child, it.parent.generated}, true
+}
+
+func (s lazyState) Expand(ctx Context) []State {
+	panic("Expand should not be called on a LazyState")
+}
+
+func (s lazyState) Successors(ctx Context) Successors {
+	return &lazySuccessors{s, s.graph[s.node]}
+}
+
+func TestLazyStates(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 8}, {"d", 10}}
+	g["b"] = []edge{{"bb", 1}}
+	g["c"] = []edge{{"cc", 8}}
+	g["d"] = []edge{{"dd", 10}}
+	g["bb"] = []edge{{"B", 200}}
+	g["cc"] = []edge{{"C", 100}}
+	g["dd"] = []edge{{"D", 1}}
+	generated := 0
+	result := NewSolver(lazyState{create(g), &generated}).Algorithm(DepthFirst).Solve()
+	if result.GoalState().(lazyState).node != "B" {
+		t.Errorf("Expected B to be found first, but was %v", result.GoalState())
+	}
+	if generated != 3 {
+		t.Errorf("Expected only the states on the path to B to be generated, but was %d", generated)
+	}
+
+	expected := []goalCost{{"D", 21}, {"C", 116.0}, {"B", 202.0}}
+	for _, algorithm := range []Algorithm{IDAstar, IterativeDeepening} {
+		var actual []goalCost
+		for result := range NewSolver(lazyState{create(g), &generated}).Algorithm(algorithm).SolveAll() {
+			actual = append(actual, goalCost{result.GoalState().(lazyState).node, result.Cost})
+		}
+		if algorithm == IterativeDeepening {
+			sort.Slice(actual, func(i, j int) bool { return actual[i].cost < actual[j].cost })
+		}
+		if !equalGoalCost(actual, expected) {
+			t.Errorf("%v - Expected %v but found %v", algorithm, expected, actual)
+		}
+	}
+}
+
+func TestIncrementalSolver(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 2}}
+	g["b"] = []edge{{"G", 1}}
+	g["c"] = []edge{{"G", 2}}
+	key := func(s State) interface{} { return s.(stepState).node }
+	solver := NewIncrementalSolver(key, nil, stepState{g, "a"})
+	check := func(expectedPath string, expectedCost float64) Result {
+		result := solver.Replan()
+		if fmt.Sprint(solutionNodes(result)) != expectedPath || result.Cost != expectedCost {
+			t.Errorf("Expected %v with costs %v, but was %v with costs %v", expectedPath, expectedCost, solutionNodes(result), result.Cost)
+		}
+		return result
+	}
+	first := check("[a b G]", 2)
+
+	g["b"] = []edge{{"G", 10}}
+	solver.Invalidate(stepState{g, "b"})
+	second := check("[a c G]", 4)
+	if second.Visited >= first.Visited+1 {
+		t.Errorf("Expected the replanning to process less states, but was %d", second.Visited)
+	}
+
+	g["c"] = nil
+	solver.Invalidate(stepState{g, "c"})
+	check("[a b G]", 11)
+
+	g["b"] = nil
+	solver.Invalidate(stepState{g, "b"})
+	check("[]", 0)
+
+	g["c"] = []edge{{"G", 1}}
+	solver.Invalidate(stepState{g, "c"})
+	check("[a c G]", 3)
+}
+
+func TestIncrementalSolverWithZeroCosts(t *testing.T) {
+	g := make(graph)
+	g["r"] = []edge{{"a", 1}}
+	g["a"] = []edge{{"a", 0}, {"b", 0}}
+	g["b"] = []edge{{"a", 0}, {"G", 0}}
+	key := func(s State) interface{} { return s.(stepState).node }
+	for i := 0; i < 50; i++ {
+		result := NewIncrementalSolver(key, nil, stepState{g, "r"}).Replan()
+		if fmt.Sprint(solutionNodes(result)) != "[r a b G]" || result.Cost != 1 {
+			t.Fatalf("Expected [r a b G] with costs 1, but was %v with costs %v", solutionNodes(result), result.Cost)
+		}
+	}
+}
+
+func TestIncrementalSolverInvalidateQueuedState(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 5}}
+	g["b"] = []edge{{"G", 1}}
+	g["c"] = []edge{{"G", 1}}
+	key := func(s State) interface{} { return s.(stepState).node }
+	solver := NewIncrementalSolver(key, nil, stepState{g, "a"})
+	solver.Replan()
+
+	// c is reached but not expanded, the new state must be used when it is expanded
+	changed := graph{"a": g["a"], "b": {{"G", 10}}, "c": {{"G", 0}}}
+	solver.Invalidate(stepState{changed, "b"})
+	solver.Invalidate(stepState{changed, "c"})
+	result := solver.Replan()
+	if fmt.Sprint(solutionNodes(result)) != "[a c G]" || result.Cost != 5 {
+		t.Errorf("Expected [a c G] with costs 5, but was %v with costs %v", solutionNodes(result), result.Cost)
+	}
+}
+
+func TestIncrementalSolverContext(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}}
+	g["b"] = []edge{{"G", 1}}
+	key := func(s State) interface{} { return s.(stepState).node }
+	var evals evaluations
+	NewIncrementalSolver(key, &evals, stepState{g, "a"}).Replan()
+	expected := "[{+Inf 0 0} {+Inf 0 0} {+Inf 0 0}]"
+	if fmt.Sprint(evals) != expected {
+		t.Errorf("Expected evaluations %v, but was %v", expected, evals)
+	}
+}
+
+func solutionNodes(result Result) []string {
+	nodes := []string{}
+	for _, s := range result.Solution {
+		nodes = append(nodes, s.(stepState).node)
+	}
+	return nodes
+}
+
+func TestIncrementalSolverWithRandomChanges(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	g := make(graph)
+	name := func(i int) string {
+		if i >= 18 {
+			return fmt.Sprintf("G%d", i)
+		}
+		return fmt.Sprintf("n%d", i)
+	}
+	randomEdges := func() []edge {
+		var edges []edge
+		for j := r.Intn(4); j > 0; j-- {
+			edges = append(edges, edge{name(r.Intn(20)), float64(1 + r.Intn(9))})
+		}
+		return edges
+	}
+	for i := 0; i < 20; i++ {
+		g[name(i)] = randomEdges()
+	}
+	key := func(s State) interface{} { return s.(stepState).node }
+	root := stepState{g, "n0"}
+	solver := NewIncrementalSolver(key, nil, root)
+	for i := 0; i < 50; i++ {
+		result := solver.Replan()
+		expected := math.Inf(1)
+		for k, cost := range NewSolver(root).Key(key).Distances() {
+			if unicode.IsUpper([]rune(k.(string))[0]) {
+				expected = math.Min(expected, cost)
+			}
+		}
+		if result.Solved() != !math.IsInf(expected, 1) || result.Solved() && result.Cost != expected {
+			t.Fatalf("Iteration %d: expected costs %v, but was %v", i, expected, result.Cost)
+		}
+		changed := name(r.Intn(20))
+		g[changed] = randomEdges()
+		solver.Invalidate(stepState{g, changed})
+	}
+}
+
+// Graph problem with a heuristic per node
+type heuristicState struct {
+	state
+	heuristic map[string]float64
+}
+
+func (s heuristicState) Expand(ctx Context) []State {
+	var children []State
+	for _, child := range s.state.Expand(ctx) {
+		children = append(children, heuristicState{child.(state), s.heuristic})
+	}
+	return children
+}
+
+func (s heuristicState) Heuristic(ctx Context) float64 {
+	recordEvaluation(ctx)
+	return s.heuristic[s.node]
+}
+
+// The evaluations of the heuristic, recorded when it is passed as custom context
+type evaluations []evaluation
+
+func recordEvaluation(ctx Context) {
+	if e, ok := ctx.Custom.(*evaluations); ok {
+		*e = append(*e, evaluation{ctx.Bound(), ctx.PathCost(), ctx.Depth()})
+	}
+}
+
+func TestAstarReopeningDropsDuplicates(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 1}}
+	g["b"] = []edge{{"d", 1}}
+	g["c"] = []edge{{"d", 2}}
+	g["d"] = []edge{{"G", 1}}
+	key := func(s State) interface{} { return s.(state).node }
+	result := NewSolver(create(g)).Algorithm(AstarReopening).Key(key).Solve()
+	if result.Cost != 3 {
+		t.Errorf("Expected solution with costs 3, but found %v", result.Cost)
+	}
+	// the more expensive path to d is not added
+	if result.Expanded != 4 {
+		t.Errorf("Expected 4 expanded nodes, but found %v", result.Expanded)
+	}
+}
+
+func TestAstarReopeningWithInconsistentHeuristic(t *testing.T) {
+	g := make(graph)
+	g["s"] = []edge{{"a", 1}, {"c", 3}}
+	g["a"] = []edge{{"c", 1}}
+	g["c"] = []edge{{"G", 5}}
+	h := map[string]float64{"a": 5}
+	root := heuristicState{state{g, "s", 0}, h}
+	key := func(s State) interface{} {
+		return s.(heuristicState).node
+	}
+
+	result := NewSolver(root).
+		Algorithm(AstarReopening).
+		Key(key).
+		Solve()
+	if result.Cost != 7 {
+		t.Errorf("Expected solution with costs 7, but found %v", result.Cost)
+	}
+	if result.Visited != 5 {
+		t.Errorf("Expected 5 visited nodes, but found %v", result.Visited)
+	}
+	solutions := 0
+	for range NewSolver(root).Algorithm(AstarReopening).Key(key).SolveAll() {
+		solutions++
+	}
+	if solutions != 1 {
+		t.Errorf("Expected a single solution since all other paths are more expensive duplicates, but found %v", solutions)
+	}
+}
+
+func TestAgent(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 1}}
+	g["b"] = []edge{{"d", 1}}
+	g["c"] = []edge{{"G", 5}}
+	g["d"] = []edge{{"G", 1}}
+	h := map[string]float64{"b": 2}
+	root := heuristicState{state{g, "a", 0}, h}
+	key := func(s State) interface{} { return s.(heuristicState).node }
+
+	agent := NewAgent(key, 1, nil)
+	first := agent.Trial(root, 10)
+	if fmt.Sprint(first.Solution) != "[a c G]" || first.Cost != 6 {
+		t.Errorf("Expected the misleading heuristic to lead to [a c G] with costs 6, but was %v, %v", first.Solution, first.Cost)
+	}
+	if agent.Heuristic(root) != 1 {
+		t.Errorf("Expected learned heuristic 1 for the root, but was %v", agent.Heuristic(root))
+	}
+	second := agent.Trial(root, 10)
+	if fmt.Sprint(second.Solution) != "[a b d G]" || second.Cost != 3 {
+		t.Errorf("Expected the second trial to find the optimal solution [a b d G], but was %v, %v", second.Solution, second.Cost)
+	}
+
+	result := NewAgent(key, 10, nil).Trial(root, 10)
+	if result.Cost != 3 {
+		t.Errorf("Expected the optimal solution with enough lookahead, but was %v, %v", result.Solution, result.Cost)
+	}
+	if _, _, ok := agent.Next(heuristicState{state{g, "G", 0}, h}); ok {
+		t.Errorf("Expected no next state for a goal")
+	}
+}
+
+func TestAgentContext(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}}
+	g["b"] = []edge{{"c", 2}}
+	g["c"] = []edge{{"G", 1}}
+	key := func(s State) interface{} { return s.(heuristicState).node }
+	var evals evaluations
+	NewAgent(key, 10, &evals).Next(heuristicState{state{g, "a", 0}, nil})
+	expected := "[{+Inf 0 0} {+Inf 1 1} {+Inf 3 2} {+Inf 4 3}]"
+	if fmt.Sprint(evals) != expected {
+		t.Errorf("Expected evaluations %v, but was %v", expected, evals)
+	}
+}
+
+// Graph with symmetric branches, where the states with a quote are symmetric to the states without
+func symmetricGraph() graph {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"b'", 1}}
+	g["b"] = []edge{{"C", 1}}
+	g["b'"] = []edge{{"C'", 1}}
+	return g
+}
+
+func canonicalStepState(s State) State {
+	st := s.(stepState)
+	return stepState{st.graph, strings.TrimSuffix(st.node, "'")}
+}
+
+func TestCanonicalize(t *testing.T) {
+	g := symmetricGraph()
+	m := NewDiskCPMap(t.TempDir(), nodeSerializer{g}, 100)
+	defer m.Close()
+	var goals []string
+	for result := range NewSolver(stepState{g, "a"}).
+		Algorithm(BreadthFirst).
+		Constraint(CheapestPathConstraint(m)).
+		Canonicalize(canonicalStepState).
+		SolveAll() {
+		goals = append(goals, result.GoalState().(stepState).node)
+	}
+	if fmt.Sprint(goals) != "[C]" {
+		t.Errorf("Expected only goal C since C' is symmetric, but found %v", goals)
+	}
+}
+
+func TestConcreteSolution(t *testing.T) {
+	g := symmetricGraph()
+	same := func(a, b State) bool {
+		return a.(stepState).node == b.(stepState).node
+	}
+	solution := []State{stepState{g, "a"}, stepState{g, "b'"}, stepState{g, "C'"}}
+	path := ""
+	for _, s := range ConcreteSolution(stepState{g, "a"}, solution, canonicalStepState, same, nil) {
+		path += s.(stepState).node
+	}
+	if path != "abC" {
+		t.Errorf("Expected concrete solution abC, but found %v", path)
+	}
+	solution = []State{stepState{g, "a"}, stepState{g, "C"}}
+	if ConcreteSolution(stepState{g, "a"}, solution, canonicalStepState, same, nil) != nil {
+		t.Error("Expected no concrete solution for invalid path")
+	}
+}
+
 type dummyState struct {
 	State
 	name string
 }
 
 func dummyNode(parent *node, name string, costs float64) *node {
-	return &node{parent, dummyState{nil, name}, costs}
+	return &node{parent: parent, state: dummyState{nil, name}, cost: costs, value: costs}
 }
 
 // for no-loop-constraint
@@ -308,57 +803,1093 @@ func TestNoLoopConstraint(t *testing.T) {
 	assert("same grandgrandparent", c.onExpand(a4), false)
 }
 
-func TestRingbuffer(t *testing.T) {
-	mknode := func(i int) *node {
-		return &node{nil, nil, float64(i)}
+func TestNodeArena(t *testing.T) {
+	var a nodeArena
+	var nodes []*node
+	for i := 0; i < 3*slabSize; i++ {
+		nodes = append(nodes, a.newNode(nil, nil, 0, float64(i), 0))
 	}
-	b := breadthFirst()
-	lastTaken := -1
-	for i := 0; i < 1000; i++ {
-		b.Add(mknode(i))
-		if i%3 == 0 {
-			taken := b.Take()
-			if taken == nil {
-				t.Errorf("Expected node %v at head of the buffer, but the buffer was empty", lastTaken+1)
-				return
-			}
-			if int(taken.value) != lastTaken+1 {
-				t.Errorf("Expected element %v from the buffer, but was %v", lastTaken+1, taken.value)
-				return
-			}
-			lastTaken = int(taken.value)
+	a.release()
+	a.release()
+	if n := a.newNode(nil, nil, 0, -1, 0); n != nodes[len(nodes)-2] {
+		t.Error("Expected released node to be reused")
+	}
+	if len(a.slabs) != 3 {
+		t.Errorf("Expected 3 slabs, but was %v", len(a.slabs))
+	}
+	for i, n := range nodes[:2*slabSize] {
+		if n.value != float64(i) {
+			t.Errorf("Expected node %v to be unchanged, but was %v", i, n.value)
+			return
 		}
 	}
+	a.reset()
+	if n := a.newNode(nil, nil, 0, 0, 0); n != nodes[0] || nodes[1].value != 0 {
+		t.Error("Expected nodes to be cleared and reused after reset")
+	}
 }
 
-func BenchmarkBreadthFirstStrategy(b *testing.B) {
-	// for breadthfirst we can reuse the node, reducing overhead
-	node := &node{nil, nil, 0}
-	for n := 0; n < b.N; n++ {
-		b := breadthFirst()
-		for i := 0; i < 3000000; i++ {
-			b.Add(node)
-			if i%3 == 0 {
-				b.Take()
-			}
-		}
+// Binary tree without goals
+type treeState struct {
+	depth int
+}
+
+func (s treeState) Cost(ctx Context) float64      { return float64(s.depth) }
+func (s treeState) IsGoal(ctx Context) bool       { return false }
+func (s treeState) Heuristic(ctx Context) float64 { return 0 }
+func (s treeState) Expand(ctx Context) []State {
+	return []State{treeState{s.depth + 1}, treeState{s.depth + 1}}
+}
+
+func TestNodeArenaDepthFirst(t *testing.T) {
+	s := newSearch(depthFirst(), NoConstraint().(iconstraint), nil, Context{}, math.Inf(1), 14)
+	s.depthFirst = true
+	s.addRoots([]State{treeState{0}})
+	r := s.run()
+	if r.visited != 1<<15-1 {
+		t.Errorf("Expected %v visited nodes, but was %v", 1<<15-1, r.visited)
+	}
+	// only the nodes on the current path and their siblings are live, which fit in a single slab
+	if len(s.nodes.slabs) != 1 {
+		t.Errorf("Expected a single slab, but was %v", len(s.nodes.slabs))
+	}
+	live := s.nodes.slab*slabSize + s.nodes.next
+	if live > 2*14 {
+		t.Errorf("Expected at most %v live nodes, but was %v", 2*14, live)
 	}
 }
 
-func BenchmarkAStarStrategy(b *testing.B) {
-	// for Astar we can not reuse the node, so this test involves more overhead
-	mknode := func(value float64) *node {
-		return &node{nil, nil, value}
+func TestTranspositionTableConstraint(t *testing.T) {
+	assert := func(name string, value, expected interface{}) {
+		if value != expected {
+			t.Errorf("%v - Expected %v, but was %v", name, expected, value)
+		}
+	}
+	states := map[string]State{}
+	for _, name := range []string{"a", "b", "d"} {
+		states[name] = dummyState{nil, name}
+	}
+	mknode := func(parent *node, name string, cost float64, depth int) *node {
+		return &node{parent: parent, state: states[name], cost: cost, value: cost, depth: depth}
+	}
+	hash := func(s State) uint64 {
+		return uint64(s.(dummyState).name[0])
 	}
 
-	r := rand.New(rand.NewSource(123))
-	for n := 0; n < b.N; n++ {
-		q := aStar()
-		for i := 0; i < 1000000; i++ {
-			q.Add(mknode(r.Float64()))
-			if i%3 == 0 {
-				q.Take()
-			}
+	c := TranspositionTableConstraint(2, hash, ReplaceByDepth).(iconstraint)
+	c.reset()
+	root := mknode(nil, "a", 0, 0)
+	b := mknode(root, "b", 2, 1)
+	assert("first b", c.onExpand(b), false)
+	assert("b with same costs in same iteration", c.onExpand(mknode(root, "b", 2, 1)), true)
+	assert("b with higher costs", c.onExpand(mknode(root, "b", 3, 1)), true)
+	b = mknode(root, "b", 1, 1)
+	assert("b with lower costs", c.onExpand(b), false)
+
+	// learn from the children of b that are at least 5 away
+	c.(learningConstraint).onExpanded(b, 6)
+	c.reset()
+	b2 := mknode(root, "b", 1, 1)
+	assert("b with same costs in next iteration", c.onExpand(b2), false)
+	assert("learned value", b2.value, 6.0)
+
+	// "b" and "d" share a slot in the table of size 2
+	assert("deeper d", c.onExpand(mknode(b, "d", 1, 2)), false)
+	assert("d does not replace b", c.onExpand(mknode(root, "b", 1, 1)), true)
+	assert("shallower d", c.onExpand(mknode(root, "d", 1, 1)), false)
+	assert("d replaces b", c.onExpand(mknode(root, "b", 1, 1)), false)
+
+	assertPanics(t, "empty table", func() { TranspositionTableConstraint(0, hash, ReplaceByDepth) })
+}
+
+func assertPanics(t *testing.T, name string, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%v - Expected a panic", name)
+		}
+	}()
+	f()
+}
+
+func TestBloomFilterConstraint(t *testing.T) {
+	hash := func(s State) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s.(dummyState).name))
+		return h.Sum64()
+	}
+	c := BloomFilterConstraint(1000, 4, hash).(iconstraint)
+	c.reset()
+	for i := 0; i < 100; i++ {
+		if c.onExpand(dummyNode(nil, fmt.Sprint(i), 0)) {
+			t.Errorf("Expected state %v not to be seen yet", i)
+		}
+		if !c.onExpand(dummyNode(nil, fmt.Sprint(i), 0)) {
+			t.Errorf("Expected state %v to be seen", i)
+		}
+	}
+	rate := c.(probabilisticConstraint).falsePositiveRate()
+	if rate < 0.01 || rate > 0.02 {
+		t.Errorf("Expected false positive rate of about 0.012, but was %v", rate)
+	}
+	c.reset()
+	if c.(probabilisticConstraint).falsePositiveRate() != 0 || c.onExpand(dummyNode(nil, "1", 0)) {
+		t.Error("Expected filter to be empty after reset")
+	}
+
+	result := NewSolver(swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}).
+		Algorithm(DepthFirst).
+		Constraint(BloomFilterConstraint(1<<20, 7, func(s State) uint64 {
+			vector := s.(swapState).vector
+			h := fnv.New64a()
+			h.Write(vector[:])
+			return h.Sum64()
+		})).
+		Solve()
+	if !result.Solved() || result.FalsePositiveRate <= 0 || result.FalsePositiveRate > 0.01 {
+		t.Errorf("Expected solution with small false positive rate, but was %v", result.FalsePositiveRate)
+	}
+
+	assertPanics(t, "no bits", func() { BloomFilterConstraint(0, 4, hash) })
+	assertPanics(t, "no hashes", func() { BloomFilterConstraint(1000, 0, hash) })
+}
+
+// Problem for move pruning: set all bits, where each operator sets a single bit
+type bitsState struct {
+	bits uint
+	op   int
+	cost int
+}
+
+func (s bitsState) Cost(ctx Context) float64 {
+	return float64(s.cost)
+}
+
+func (s bitsState) IsGoal(ctx Context) bool {
+	return s.bits == 7
+}
+
+func (s bitsState) Expand(ctx Context) []State {
+	var children []State
+	for i := 0; i < 3; i++ {
+		if s.bits&(1<<uint(i)) == 0 {
+			children = append(children, bitsState{s.bits | 1<<uint(i), i, s.cost + 1})
+		}
+	}
+	return children
+}
+
+func (s bitsState) Heuristic(ctx Context) float64 {
+	return 0
+}
+
+func (s bitsState) Operator() interface{} {
+	return s.op
+}
+
+func TestMovePruningConstraint(t *testing.T) {
+	commute := func(a, b interface{}) bool {
+		return a != b
+	}
+	less := func(a, b interface{}) bool {
+		return a.(int) < b.(int)
+	}
+	for _, algorithm := range []Algorithm{DepthFirst, IDAstar} {
+		count := func(constraint Constraint) int {
+			solutions := 0
+			for range NewSolver(bitsState{0, -1, 0}).Algorithm(algorithm).Constraint(constraint).SolveAll() {
+				solutions++
+			}
+			return solutions
+		}
+		if n := count(NoConstraint()); n != 6 {
+			t.Errorf("%v - Expected all 6 orderings without move pruning, but found %v", algorithm, n)
+		}
+		if n := count(MovePruningConstraint(commute, less)); n != 1 {
+			t.Errorf("%v - Expected a single ordering with move pruning, but found %v", algorithm, n)
+		}
+	}
+}
+
+func TestRingbuffer(t *testing.T) {
+	mknode := func(i int) *node {
+		return &node{value: float64(i)}
+	}
+	b := breadthFirst()
+	lastTaken := -1
+	for i := 0; i < 1000; i++ {
+		b.Add(mknode(i))
+		if i%3 == 0 {
+			taken := b.Take()
+			if taken == nil {
+				t.Errorf("Expected node %v at head of the buffer, but the buffer was empty", lastTaken+1)
+				return
+			}
+			if int(taken.value) != lastTaken+1 {
+				t.Errorf("Expected element %v from the buffer, but was %v", lastTaken+1, taken.value)
+				return
+			}
+			lastTaken = int(taken.value)
+		}
+	}
+}
+
+func TestTieBreaking(t *testing.T) {
+	mknode := func(name string, cost float64, depth int) *node {
+		return &node{state: dummyState{nil, name}, cost: cost, value: 10, depth: depth}
+	}
+	byName := func(a, b State) bool {
+		return a.(dummyState).name > b.(dummyState).name
+	}
+	tests := []struct {
+		tieBreaker TieBreaker
+		expected   string
+	}{
+		{FIFO(), "abcd"},
+		{LIFO(), "dcba"},
+		{HighestCostFirst(), "cdba"},
+		{DeepestFirst(), "bdac"},
+		{CustomTieBreaker(byName), "dcba"},
+	}
+	for _, test := range tests {
+		for _, q := range []strategy{aStar(test.tieBreaker.(tieBreaker)), integerAStar(test.tieBreaker.(tieBreaker))} {
+			q.Add(mknode("a", 1, 1))
+			q.Add(mknode("b", 2, 3))
+			q.Add(mknode("c", 5, 1))
+			q.Add(mknode("d", 5, 2))
+			q.Add(&node{state: dummyState{nil, "x"}, cost: 99, value: 11, depth: 99})
+			actual := ""
+			for i := 0; i < 4; i++ {
+				actual += q.Take().state.(dummyState).name
+			}
+			if actual != test.expected {
+				t.Errorf("%v - Expected order %v, but was %v", test.tieBreaker, test.expected, actual)
+			}
+		}
+	}
+}
+
+func TestBucketQueue(t *testing.T) {
+	r := rand.New(rand.NewSource(123))
+	q := integerAStar(FIFO().(tieBreaker))
+	q.Add(&node{value: math.Inf(1)})
+	q.Add(&node{value: math.MaxFloat64})
+	var values []float64
+	for i := 0; i < 1000; i++ {
+		value := float64(r.Intn(100) - 50)
+		values = append(values, value)
+		q.Add(&node{value: value})
+	}
+	sort.Float64s(values)
+	values = append(values, math.MaxFloat64, math.Inf(1))
+	for i, expected := range values {
+		if n := q.Take(); n == nil || n.value != expected {
+			t.Errorf("Expected value %v at position %v, but was %v", expected, i, n)
+			return
+		}
+	}
+	if q.Take() != nil {
+		t.Error("Expected empty queue")
+	}
+}
+
+func TestBucketQueueWithSparseValues(t *testing.T) {
+	r := rand.New(rand.NewSource(123))
+	q := integerAStar(FIFO().(tieBreaker))
+	var values []float64
+	for i := 0; i < 1000; i++ {
+		var value float64
+		switch i % 4 {
+		case 0:
+			value = float64(r.Intn(100))
+		case 1:
+			value = float64(r.Intn(100)) + 0.5
+		case 2:
+			value = float64(r.Int63n(1 << 40))
+		case 3:
+			value = -float64(r.Intn(1 << 20))
+		}
+		values = append(values, value)
+		q.Add(&node{value: value})
+	}
+	sort.Float64s(values)
+	for i, expected := range values {
+		if n := q.Take(); n == nil || n.value != expected {
+			t.Errorf("Expected value %v at position %v, but was %v", expected, i, n)
+			return
+		}
+	}
+	if n := len(q.(*bucketQueue).buckets); n > bucketWindow {
+		t.Errorf("Expected at most %v buckets, but was %v", bucketWindow, n)
+	}
+}
+
+func TestBucketQueueTieBreakingAfterMove(t *testing.T) {
+	for _, tie := range []TieBreaker{FIFO(), LIFO()} {
+		q := integerAStar(tie.(tieBreaker))
+		q.Add(&node{value: 1})
+		for i := 0; i < 3; i++ {
+			q.Add(&node{value: 1 << 30, depth: i})
+		}
+		q.Take()
+		q.Add(&node{value: 1 << 30, depth: 3})
+		actual := ""
+		for n := q.Take(); n != nil; n = q.Take() {
+			actual += strconv.Itoa(n.depth)
+		}
+		expected := map[bool]string{false: "0123", true: "3210"}[tie.(tieBreaker).lifo]
+		if actual != expected {
+			t.Errorf("%v - Expected order %v, but was %v", tie, expected, actual)
+		}
+	}
+}
+
+func TestIntegerCosts(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 8}, {"d", 10}}
+	g["b"] = []edge{{"bb", 1}}
+	g["c"] = []edge{{"cc", 8}}
+	g["d"] = []edge{{"dd", 10}}
+	g["bb"] = []edge{{"B", 200}}
+	g["cc"] = []edge{{"C", 100}}
+	g["dd"] = []edge{{"D", 1}}
+	expected := []goalCost{{"D", 21}, {"C", 116.0}, {"B", 202.0}}
+	actual := solveAll(NewSolver(create(g)).IntegerCosts(true))
+	if !equalGoalCost(actual, expected) {
+		t.Errorf("Expected %v but found %v", expected, actual)
+	}
+}
+
+func TestHeuristicCombinators(t *testing.T) {
+	one := func(state State, ctx Context) float64 { return 1 }
+	two := func(state State, ctx Context) float64 { return 2 }
+	calls := 0
+	three := func(state State, ctx Context) float64 {
+		calls++
+		return 3
+	}
+	s := swapState{}
+	shallow := Context{eval: &evaluation{math.Inf(1), 0, 0}}
+	deep := Context{eval: &evaluation{math.Inf(1), 0, 2}}
+	key := func(state State) interface{} { return state.(swapState).vector }
+	cached := Cached(key, three)
+	cached(s, shallow)
+
+	cases := []struct {
+		name     string
+		h        HeuristicFunc
+		ctx      Context
+		expected float64
+	}{
+		{"max", Max(one, two), shallow, 2},
+		{"sum", Sum(one, two), shallow, 3},
+		{"weighted", Sum(Weighted(2, one), Weighted(0.5, two)), shallow, 3},
+		{"shallow", SwitchAtDepth(1, one, two), shallow, 1},
+		{"deep", SwitchAtDepth(1, one, two), deep, 2},
+		{"cached", cached, shallow, 3},
+		{"lazy", Lazy(two, three), Context{eval: &evaluation{3, 1, 0}}, 3},
+		{"lazy exceeding bound", Lazy(two, three), Context{eval: &evaluation{3, 2, 0}}, 2},
+	}
+	for _, c := range cases {
+		if value := c.h(s, c.ctx); value != c.expected {
+			t.Errorf("%s: expected %v, but was %v", c.name, c.expected, value)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls of the heuristic, once for cached and once for lazy, but was %d", calls)
+	}
+}
+
+// Number of inversions in the swapState, which is a better heuristic than the one of the state
+func inversions(state State, ctx Context) float64 {
+	v := state.(swapState).vector
+	count := 0
+	for i := range v {
+		for j := i + 1; j < len(v); j++ {
+			if v[i] > v[j] {
+				count++
+			}
+		}
+	}
+	return float64(count)
+}
+
+func TestSolverHeuristic(t *testing.T) {
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Solve()
+	result := NewSolver(root).Heuristic(inversions).Solve()
+	if result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if result.Visited >= expected.Visited {
+		t.Errorf("Expected less than %d visited nodes with the better heuristic, but was %d", expected.Visited, result.Visited)
+	}
+	key := func(s State) interface{} { return s.(swapState).vector }
+	canonical := func(s State) State { return s.(swapState) }
+	reopening := NewSolver(root).Heuristic(inversions).Algorithm(AstarReopening).Key(key).Canonicalize(canonical).Solve()
+	if reopening.Cost != expected.Cost {
+		t.Errorf("Expected costs %v with the key and canonical states, but was %v", expected.Cost, reopening.Cost)
+	}
+
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"C", 3}}
+	g["b"] = []edge{{"C", 1}}
+	zero := func(state State, ctx Context) float64 { return 0 }
+	stepResult := NewSolver(stepState{g, "a"}).Heuristic(zero).Solve()
+	if stepResult.Cost != 2 || stepResult.GoalState().(stepState).node != "C" {
+		t.Errorf("Expected C with costs 2, but was %v", stepResult)
+	}
+}
+
+// Tests the heuristic of the solver created by newSolver with states that implement the optional interfaces
+func testHeuristicWithOptionalInterfaces(t *testing.T, newSolver func(State, HeuristicFunc) Solver) {
+	zero := func(state State, ctx Context) float64 { return 0 }
+
+	// StepState
+	steps := make(graph)
+	steps["a"] = []edge{{"b", 1}, {"C", 3}}
+	steps["b"] = []edge{{"C", 1}}
+	if result := newSolver(stepState{steps, "a"}, zero).Solve(); result.Cost != 2 {
+		t.Errorf("Expected costs 2, but was %v", result.Cost)
+	}
+
+	// OperatorState
+	commute := func(a, b interface{}) bool { return a != b }
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+	solutions := 0
+	for range newSolver(bitsState{0, -1, 0}, zero).Algorithm(DepthFirst).Constraint(MovePruningConstraint(commute, less)).SolveAll() {
+		solutions++
+	}
+	if solutions != 1 {
+		t.Errorf("Expected a single ordering with move pruning, but found %v", solutions)
+	}
+
+	// LazyState
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 8}}
+	g["b"] = []edge{{"B", 200}}
+	g["c"] = []edge{{"C", 100}}
+	generated := 0
+	result := newSolver(lazyState{create(g), &generated}, zero).Algorithm(DepthFirst).Solve()
+	if result.GoalState().(lazyState).node != "B" || generated != 2 {
+		t.Errorf("Expected B with 2 generated states, but was %v with %d generated states", result.GoalState(), generated)
+	}
+
+	// PartialExpansionState
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	calls := 0
+	h := func(state State, ctx Context) float64 { return inversions(state.(bandState).swapState, ctx) }
+	result = newSolver(bandState{root, &calls}, h).Algorithm(PartialExpansionAstar).Solve()
+	if expected := NewSolver(root).Solve(); result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if calls == 0 {
+		t.Errorf("Expected ExpandBand to be used")
+	}
+
+	// MultiObjectiveState
+	mo := map[string][]moEdge{
+		"a": {{"b", [2]float64{1, 10}}, {"c", [2]float64{5, 1}}},
+		"b": {{"G", [2]float64{1, 0}}},
+		"c": {{"G", [2]float64{5, 1}}},
+	}
+	key := func(s State) interface{} { return s.(moState).node }
+	if results := newSolver(moState{mo, "a", [2]float64{}}, zero).Key(key).SolvePareto(); len(results) != 2 {
+		t.Errorf("Expected 2 Pareto optimal solutions, but was %v", len(results))
+	}
+}
+
+func TestSolverHeuristicWithOptionalInterfaces(t *testing.T) {
+	testHeuristicWithOptionalInterfaces(t, func(root State, h HeuristicFunc) Solver {
+		return NewSolver(root).Heuristic(h)
+	})
+}
+
+func TestWithHeuristic(t *testing.T) {
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Heuristic(inversions).Solve()
+	result := NewSolver(WithHeuristic(root, inversions)).Solve()
+	if result.Cost != expected.Cost || result.Visited != expected.Visited {
+		t.Errorf("Expected the same search as with Solver.Heuristic, but was %v", result)
+	}
+	if _, ok := result.Solution[0].(swapState); !ok {
+		t.Errorf("Expected the root state to be unwrapped, but was %T", result.Solution[0])
+	}
+	testHeuristicWithOptionalInterfaces(t, func(root State, h HeuristicFunc) Solver {
+		return NewSolver(WithHeuristic(root, h))
+	})
+}
+
+func TestLazyHeuristic(t *testing.T) {
+	calls := 0
+	expensive := func(state State, ctx Context) float64 {
+		calls++
+		return inversions(state, ctx)
+	}
+	cheap := func(state State, ctx Context) float64 {
+		return state.Heuristic(ctx)
+	}
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Heuristic(Max(cheap, expensive)).Algorithm(IDAstar).Solve()
+	maxCalls := calls
+	calls = 0
+	result := NewSolver(root).Heuristic(Lazy(cheap, expensive)).Algorithm(IDAstar).Solve()
+	if result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if calls >= maxCalls {
+		t.Errorf("Expected less than %d calls of the expensive heuristic, but was %d", maxCalls, calls)
+	}
+}
+
+func TestHeuristicCache(t *testing.T) {
+	c := newHeuristicCache(nil, 2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.get("a")
+	c.put("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Errorf("Expected least recently used value to be evicted")
+	}
+	if value, ok := c.get("a"); !ok || value != 1 {
+		t.Errorf("Expected 1, but was %v, %v", value, ok)
+	}
+
+	calls := 0
+	counting := func(state State, ctx Context) float64 {
+		calls++
+		return inversions(state, ctx)
+	}
+	key := func(state State) interface{} { return state.(swapState).vector }
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Heuristic(counting).Algorithm(IDAstar).Solve()
+	uncached := calls
+	calls = 0
+	result := NewSolver(root).Heuristic(counting).Algorithm(IDAstar).HeuristicCache(key, 1<<16).Solve()
+	if result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if calls >= uncached {
+		t.Errorf("Expected less than %d calls of the heuristic, but was %d", uncached, calls)
+	}
+}
+
+type vectorCPMap map[[8]byte]float64
+
+func (c vectorCPMap) Get(s State) (float64, bool) {
+	value, ok := c[s.(swapState).vector]
+	return value, ok
+}
+
+func (c vectorCPMap) Put(s State, value float64) {
+	c[s.(swapState).vector] = value
+}
+
+func (c *vectorCPMap) Clear() {
+	*c = make(vectorCPMap)
+}
+
+func TestHeuristicNotComputedForDroppedStates(t *testing.T) {
+	calls := 0
+	counting := func(state State, ctx Context) float64 {
+		calls++
+		return inversions(state, ctx)
+	}
+	m := make(vectorCPMap)
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	result := NewSolver(root).Heuristic(counting).Constraint(CheapestPathConstraint(&m)).Solve()
+	if !result.Solved() {
+		t.Fatalf("Expected a solution")
+	}
+	// once for the root state and once for each expanded state
+	if calls != result.Expanded+1 {
+		t.Errorf("Expected %d calls of the heuristic, but was %d", result.Expanded+1, calls)
+	}
+}
+
+// The constraint sees the child before its heuristic is computed, so it must compare the costs of the paths and not
+// the values, which would also drop the child itself when it is visited
+func TestCheapestPathConstraintComparesCosts(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 2}}
+	g["b"] = []edge{{"d", 3}}
+	g["c"] = []edge{{"d", 1}}
+	g["d"] = []edge{{"E", 1}}
+	h := map[string]float64{"b": 3, "c": 1, "d": 1}
+	heuristic := func(s State, ctx Context) float64 {
+		return h[s.(state).node]
+	}
+	for _, algorithm := range []Algorithm{Astar, IDAstar, PartialExpansionAstar, FringeSearch} {
+		m := make(cpMap)
+		result := NewSolver(create(g)).Algorithm(algorithm).Heuristic(heuristic).Constraint(CheapestPathConstraint(&m)).Solve()
+		if !result.Solved() || result.Cost != 4 {
+			t.Errorf("%v: expected a solution with costs 4, but was %v", algorithm, result)
+		}
+	}
+}
+
+// Generates only the children in the requested band
+type bandState struct {
+	swapState
+	calls *int
+}
+
+func (s bandState) Expand(ctx Context) []State {
+	panic("Expand should not be called on a PartialExpansionState")
+}
+
+func (s bandState) Heuristic(ctx Context) float64 {
+	return inversions(s.swapState, ctx)
+}
+
+func (s bandState) ExpandBand(ctx Context, low float64, high float64) ([]State, float64) {
+	*s.calls++
+	var children []State
+	next := math.Inf(1)
+	value := s.Cost(ctx) + s.Heuristic(ctx)
+	for _, child := range s.swapState.Expand(ctx) {
+		c := bandState{child.(swapState), s.calls}
+		delta := c.Cost(ctx) + c.Heuristic(ctx) - value
+		if delta >= low && delta <= high {
+			children = append(children, c)
+		} else if delta > high {
+			next = math.Min(next, delta)
+		}
+	}
+	return children, next
+}
+
+func TestPartialExpansionAstar(t *testing.T) {
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Solve()
+	result := NewSolver(root).Algorithm(PartialExpansionAstar).Solve()
+	if result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if result.Expanded >= expected.Expanded {
+		t.Errorf("Expected less than %d nodes in the queue, but was %d", expected.Expanded, result.Expanded)
+	}
+
+	calls := 0
+	bandResult := NewSolver(bandState{root, &calls}).Algorithm(PartialExpansionAstar).Solve()
+	expected = NewSolver(root).Heuristic(inversions).Solve()
+	if bandResult.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, bandResult.Cost)
+	}
+	if calls == 0 {
+		t.Errorf("Expected ExpandBand to be used")
+	}
+
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"C", 8}, {"D", 10}}
+	g["b"] = []edge{{"B", 200}}
+	actual := solveAll(NewSolver(create(g)).Algorithm(PartialExpansionAstar).Limit(9))
+	if !equalGoalCost(actual, []goalCost{{"C", 8}}) {
+		t.Errorf("Expected only C within the limit, but found %v", actual)
+	}
+}
+
+func TestPartialExpansionAstarComputesHeuristicOncePerChild(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 0.1}, {"c", 0.2}, {"d", 0.7}, {"E", 0.9}}
+	calls := make(map[string]int)
+	counting := func(s State, ctx Context) float64 {
+		calls[s.(state).node]++
+		return 0
+	}
+	result := NewSolver(create(g)).Algorithm(PartialExpansionAstar).Heuristic(counting).Solve()
+	if !result.Solved() || result.GoalState().(state).node != "E" {
+		t.Fatalf("Expected to find E, but was %v", result)
+	}
+	for node, count := range calls {
+		if count != 1 {
+			t.Errorf("Expected the heuristic of %v to be computed once, but was %d", node, count)
+		}
+	}
+}
+
+func TestFringeSearch(t *testing.T) {
+	root := swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}
+	expected := NewSolver(root).Algorithm(IDAstar).Solve()
+	result := NewSolver(root).Algorithm(FringeSearch).Solve()
+	if result.Cost != expected.Cost {
+		t.Errorf("Expected costs %v, but was %v", expected.Cost, result.Cost)
+	}
+	if result.Visited >= expected.Visited {
+		t.Errorf("Expected less than %d visited nodes, but was %d", expected.Visited, result.Visited)
+	}
+
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"C", 8}, {"D", 10}}
+	g["b"] = []edge{{"B", 200}}
+	actual := solveAll(NewSolver(create(g)).Algorithm(FringeSearch).Limit(9))
+	if !equalGoalCost(actual, []goalCost{{"C", 8}}) {
+		t.Errorf("Expected only C within the limit, but found %v", actual)
+	}
+}
+
+func TestDepthFirstBranchAndBound(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 1}, {"d", 1}}
+	g["b"] = []edge{{"B", 5}}
+	g["c"] = []edge{{"C", 3}, {"cc", 10}}
+	g["cc"] = []edge{{"CC", 1}}
+	g["d"] = []edge{{"D", 10}}
+	expected := []goalCost{{"D", 11}, {"C", 4}}
+	actual := solveAll(NewSolver(create(g)).Algorithm(DepthFirstBranchAndBound))
+	if !equalGoalCost(actual, expected) {
+		t.Errorf("Expected %v but found %v", expected, actual)
+	}
+
+	// the limit bounds the depth-first search until the first solution is found
+	root := swapState{[8]byte{2, 1, 4, 3, 5, 6, 8, 7}, 0}
+	var last Result
+	solver := NewSolver(root).Heuristic(inversions).Algorithm(DepthFirstBranchAndBound).Limit(7)
+	for result := range solver.SolveAll() {
+		last = result
+	}
+	optimal := NewSolver(root).Solve()
+	if last.Cost != optimal.Cost {
+		t.Errorf("Expected the last solution to be optimal with costs %v, but was %v", optimal.Cost, last.Cost)
+	}
+}
+
+func TestDistances(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"b", 1}, {"c", 5}}
+	g["b"] = []edge{{"c", 1}, {"a", 1}}
+	g["c"] = []edge{{"d", 3}}
+	g["e"] = []edge{{"a", 1}}
+	key := func(s State) interface{} { return s.(state).node }
+	distances := NewSolver(create(g)).Key(key).Distances()
+	expected := map[interface{}]float64{"a": 0, "b": 1, "c": 2, "d": 5}
+	if fmt.Sprint(distances) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but was %v", expected, distances)
+	}
+
+	distances = NewSolver(create(g)).Key(key).Distances("c", "e")
+	expected = map[interface{}]float64{"c": 2}
+	if fmt.Sprint(distances) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but was %v", expected, distances)
+	}
+
+	distances = NewSolver(create(g)).Key(key).Limit(2).Distances()
+	expected = map[interface{}]float64{"a": 0, "b": 1, "c": 2}
+	if fmt.Sprint(distances) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but was %v", expected, distances)
+	}
+
+	stepKey := func(s State) interface{} { return s.(stepState).node }
+	distances = NewMultiSolver(stepState{g, "a"}, stepState{g, "c"}).Key(stepKey).Distances()
+	expected = map[interface{}]float64{"a": 0, "b": 1, "c": 0, "d": 3}
+	if fmt.Sprint(distances) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but was %v", expected, distances)
+	}
+}
+
+func TestKShortestPaths(t *testing.T) {
+	g := make(graph)
+	g["a"] = []edge{{"d", 3}, {"e", 2}}
+	g["d"] = []edge{{"f", 4}}
+	g["e"] = []edge{{"a", 1}, {"d", 1}, {"f", 2}, {"g", 3}}
+	g["f"] = []edge{{"g", 2}, {"H", 1}}
+	g["g"] = []edge{{"H", 2}}
+	key := func(s State) interface{} { return s.(state).node }
+	costs := func(results []Result) []float64 {
+		var costs []float64
+		for _, r := range results {
+			costs = append(costs, r.Cost)
+		}
+		return costs
+	}
+
+	results := NewSolver(create(g)).Key(key).KShortestPaths(3, 0)
+	if fmt.Sprint(costs(results)) != "[5 7 8]" {
+		t.Errorf("Expected costs [5 7 8], but was %v", costs(results))
+	}
+	if fmt.Sprint(results[1].Solution) != "[a e g H]" {
+		t.Errorf("Expected [a e g H], but was %v", results[1].Solution)
+	}
+
+	// all paths without loops
+	results = NewSolver(create(g)).Key(key).KShortestPaths(10, 0)
+	if fmt.Sprint(costs(results)) != "[5 7 8 8 8 11 11]" {
+		t.Errorf("Expected costs [5 7 8 8 8 11 11], but was %v", costs(results))
+	}
+
+	results = NewSolver(create(g)).Key(key).KShortestPaths(10, 2)
+	if fmt.Sprint(costs(results)) != "[5 11]" {
+		t.Errorf("Expected costs [5 11], but was %v", costs(results))
+	}
+	if fmt.Sprint(results[1].Solution) != "[a d f g H]" {
+		t.Errorf("Expected [a d f g H], but was %v", results[1].Solution)
+	}
+}
+
+func TestKShortestPathsOnGrid(t *testing.T) {
+	// grid of size x size with the goal in the opposite corner, which has many paths of equal costs
+	size := 8
+	name := func(x, y int) string {
+		if x == size-1 && y == size-1 {
+			return "G"
+		}
+		return fmt.Sprintf("%d,%d", x, y)
+	}
+	g := make(graph)
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			var edges []edge
+			for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				if nx, ny := x+d[0], y+d[1]; nx >= 0 && nx < size && ny >= 0 && ny < size {
+					edges = append(edges, edge{name(nx, ny), 1})
+				}
+			}
+			g[name(x, y)] = edges
+		}
+	}
+	key := func(s State) interface{} { return s.(stepState).node }
+	results := NewSolver(stepState{g, "0,0"}).Key(key).KShortestPaths(5, 0)
+	if len(results) != 5 || results[4].Cost != 14 {
+		t.Fatalf("Expected 5 paths with costs 14, but was %v", len(results))
+	}
+	// a spur search for each state of the paths, which visits each state about once
+	if bound := 5 * (2*size - 1) * size * size; results[4].Visited > bound {
+		t.Errorf("Expected at most %v visited states, but was %v", bound, results[4].Visited)
+	}
+}
+
+// Graph with two costs per edge, like time and risk
+type moEdge struct {
+	target string
+	costs  [2]float64
+}
+
+type moState struct {
+	graph map[string][]moEdge
+	node  string
+	costs [2]float64
+}
+
+func (s moState) String() string { return s.node }
+func (s moState) Cost(ctx Context) float64 {
+	panic("Cost should not be called on a MultiObjectiveState")
+}
+func (s moState) Heuristic(ctx Context) float64 {
+	panic("Heuristic should not be called on a MultiObjectiveState")
+}
+func (s moState) IsGoal(ctx Context) bool          { return unicode.IsUpper([]rune(s.node)[0]) }
+func (s moState) Costs(ctx Context) []float64      { return s.costs[:] }
+func (s moState) Heuristics(ctx Context) []float64 { return []float64{0, 0} }
+
+func (s moState) Expand(ctx Context) []State {
+	var children []State
+	for _, e := range s.graph[s.node] {
+		children = append(children, moState{s.graph, e.target, [2]float64{s.costs[0] + e.costs[0], s.costs[1] + e.costs[1]}})
+	}
+	return children
+}
+
+func TestSolvePareto(t *testing.T) {
+	g := map[string][]moEdge{
+		"a": {{"b", [2]float64{1, 10}}, {"c", [2]float64{5, 1}}, {"d", [2]float64{3, 3}}, {"e", [2]float64{4, 8}}},
+		"b": {{"G", [2]float64{1, 0}}},
+		"c": {{"G", [2]float64{5, 1}}, {"a", [2]float64{1, 1}}},
+		"d": {{"G", [2]float64{3, 3}}},
+		"e": {{"G", [2]float64{4, 8}}},
+	}
+	key := func(s State) interface{} { return s.(moState).node }
+	results := NewSolver(moState{g, "a", [2]float64{}}).Key(key).SolvePareto()
+	var actual []string
+	for _, r := range results {
+		actual = append(actual, fmt.Sprintf("%v %v", r.Solution, r.Costs))
+	}
+	expected := []string{"[a b G] [2 10]", "[a d G] [6 6]", "[a c G] [10 2]"}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, but was %v", expected, actual)
+	}
+	if results[0].Cost != 2 {
+		t.Errorf("Expected costs of the first objective, but was %v", results[0].Cost)
+	}
+	if results[2].Expanded != 5 {
+		t.Errorf("Expected the labels of a, b, c, d and e to be expanded, but was %d", results[2].Expanded)
+	}
+
+	assertPanics(t, "no MultiObjectiveState", func() { NewSolver(create(graph{})).Key(key).SolvePareto() })
+
+	// solutions with equal costs don't extend the Pareto front
+	g = map[string][]moEdge{
+		"a": {{"B", [2]float64{1, 2}}, {"C", [2]float64{1, 2}}},
+	}
+	results = NewSolver(moState{g, "a", [2]float64{}}).Key(key).SolvePareto()
+	if len(results) != 1 || fmt.Sprint(results[0].Costs) != "[1 2]" {
+		t.Errorf("Expected a single solution with costs [1 2], but was %v", results)
+	}
+}
+
+// AND/OR graph in which each decomposition is a list of subproblems with its costs as the first element
+type aoState struct {
+	graph map[string][][]string
+	node  string
+}
+
+func (s aoState) Heuristic(ctx Context) float64 {
+	recordEvaluation(ctx)
+	return 0
+}
+
+func (s aoState) Decompose(ctx Context) []Decomposition {
+	var decompositions []Decomposition
+	for _, d := range s.graph[s.node] {
+		cost, _ := strconv.ParseFloat(d[0], 64)
+		var subproblems []AndOrState
+		for _, sub := range d[1:] {
+			subproblems = append(subproblems, aoState{s.graph, sub})
+		}
+		decompositions = append(decompositions, Decomposition{cost, subproblems})
+	}
+	return decompositions
+}
+
+func formatTree(t *SolutionTree) string {
+	if len(t.Subtrees) == 0 {
+		return t.State.(aoState).node
+	}
+	var subtrees []string
+	for _, sub := range t.Subtrees {
+		subtrees = append(subtrees, formatTree(sub))
+	}
+	return fmt.Sprintf("%v(%v)", t.State.(aoState).node, strings.Join(subtrees, " "))
+}
+
+func TestSolveAndOr(t *testing.T) {
+	g := map[string][][]string{
+		"a": {{"1", "b", "c"}, {"1", "d"}, {"0", "x"}},
+		"b": {{"2"}},
+		"c": {{"1", "e"}},
+		"d": {{"1", "f"}},
+		"e": {{"1"}, {"1", "b"}},
+		"f": {{"2.5"}},
+	}
+	key := func(s AndOrState) interface{} { return s.(aoState).node }
+
+	result := SolveAndOr(aoState{g, "a"}, key, SumCosts, nil)
+	if formatTree(result.Solution) != "a(d(f))" || result.Cost != 4.5 {
+		t.Errorf("Expected a(d(f)) with costs 4.5, but was %v with costs %v", formatTree(result.Solution), result.Cost)
+	}
+
+	result = SolveAndOr(aoState{g, "a"}, key, MaxCosts, nil)
+	if formatTree(result.Solution) != "a(b c(e))" || result.Cost != 3 {
+		t.Errorf("Expected a(b c(e)) with costs 3, but was %v with costs %v", formatTree(result.Solution), result.Cost)
+	}
+	if result.Solution.Subtrees[1].Cost != 2 {
+		t.Errorf("Expected costs 2 for c, but was %v", result.Solution.Subtrees[1].Cost)
+	}
+
+	result = SolveAndOr(aoState{g, "x"}, key, SumCosts, nil)
+	if result.Solution != nil {
+		t.Errorf("Expected no solution, but was %v", formatTree(result.Solution))
+	}
+}
+
+func TestSolveAndOrContext(t *testing.T) {
+	g := map[string][][]string{"a": {{"1", "b"}}, "b": {{"1"}}}
+	key := func(s AndOrState) interface{} { return s.(aoState).node }
+	var evals evaluations
+	SolveAndOr(aoState{g, "a"}, key, SumCosts, &evals)
+	expected := "[{+Inf 0 0} {+Inf 0 0}]"
+	if fmt.Sprint(evals) != expected {
+		t.Errorf("Expected evaluations %v, but was %v", expected, evals)
+	}
+}
+
+// Problem for benchmarking the algorithms: sort a vector by swapping neighbouring elements
+type swapState struct {
+	vector [8]byte
+	cost   int
+}
+
+func (s swapState) Cost(ctx Context) float64 {
+	return float64(s.cost)
+}
+
+func (s swapState) IsGoal(ctx Context) bool {
+	for i := 1; i < len(s.vector); i++ {
+		if s.vector[i-1] > s.vector[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s swapState) Expand(ctx Context) []State {
+	children := make([]State, len(s.vector)-1)
+	for i := range children {
+		child := swapState{s.vector, s.cost + 1}
+		child.vector[i], child.vector[i+1] = child.vector[i+1], child.vector[i]
+		children[i] = child
+	}
+	return children
+}
+
+func (s swapState) Heuristic(ctx Context) float64 {
+	inversions := 0
+	for i := range s.vector {
+		for j := i + 1; j < len(s.vector); j++ {
+			if s.vector[i] > s.vector[j] {
+				inversions++
+			}
+		}
+	}
+	return float64(inversions / 2)
+}
+
+func benchmarkAlgorithm(b *testing.B, algorithm Algorithm) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		result := NewSolver(swapState{[8]byte{4, 3, 8, 1, 2, 5, 6, 7}, 0}).
+			Algorithm(algorithm).
+			Solve()
+		if !result.Solved() {
+			b.Fatal("Expected a solution")
+		}
+	}
+}
+
+func BenchmarkAStar(b *testing.B) {
+	benchmarkAlgorithm(b, Astar)
+}
+
+func BenchmarkIDAStar(b *testing.B) {
+	benchmarkAlgorithm(b, IDAstar)
+}
+
+func BenchmarkBreadthFirstStrategy(b *testing.B) {
+	// for breadthfirst we can reuse the node, reducing overhead
+	node := &node{}
+	for n := 0; n < b.N; n++ {
+		b := breadthFirst()
+		for i := 0; i < 3000000; i++ {
+			b.Add(node)
+			if i%3 == 0 {
+				b.Take()
+			}
+		}
+	}
+}
+
+func BenchmarkAStarStrategy(b *testing.B) {
+	// for Astar we can not reuse the node, so this test involves more overhead
+	mknode := func(value float64) *node {
+		return &node{value: value}
+	}
+
+	r := rand.New(rand.NewSource(123))
+	for n := 0; n < b.N; n++ {
+		q := aStar(FIFO().(tieBreaker))
+		for i := 0; i < 1000000; i++ {
+			q.Add(mknode(r.Float64()))
+			if i%3 == 0 {
+				q.Take()
+			}
 		}
 	}
 }
@@ -366,12 +1897,29 @@ func BenchmarkAStarStrategy(b *testing.B) {
 func BenchmarkAStarStrategyDiscrete(b *testing.B) {
 	// for Astar we can not reuse the node, so this test involves more overhead
 	mknode := func(value float64) *node {
-		return &node{nil, nil, value}
+		return &node{value: value}
+	}
+
+	r := rand.New(rand.NewSource(123))
+	for n := 0; n < b.N; n++ {
+		q := aStar(FIFO().(tieBreaker))
+		for i := 0; i < 1000000; i++ {
+			q.Add(mknode(float64(r.Intn(100))))
+			if i%3 == 0 {
+				q.Take()
+			}
+		}
+	}
+}
+
+func BenchmarkBucketStrategyDiscrete(b *testing.B) {
+	mknode := func(value float64) *node {
+		return &node{value: value}
 	}
 
 	r := rand.New(rand.NewSource(123))
 	for n := 0; n < b.N; n++ {
-		q := aStar()
+		q := integerAStar(FIFO().(tieBreaker))
 		for i := 0; i < 1000000; i++ {
 			q.Add(mknode(float64(r.Intn(100))))
 			if i%3 == 0 {
@@ -2,6 +2,7 @@ package solve
 
 import (
 	"container/heap"
+	"math"
 )
 
 // Algorithm to be used to solve the problem
@@ -39,6 +40,58 @@ const (
 	//
 	// Will find the optimal solution if the heuristic is admissible
 	IDAstar Algorithm = iota
+
+	// IterativeDeepening performs iterative depth-first searches with an increasing maximum depth. Like IDA*
+	// it uses very little memory, but it uses the depth instead of the costs and heuristic. It is therefore
+	// intended for problems where each step has the same costs and no heuristic is available.
+	//
+	// Will find the solution with the least number of steps first. The iterations stop at the MaxDepth of the
+	// solver if one is provided.
+	IterativeDeepening Algorithm = iota
+
+	// AstarReopening is A* with duplicate detection on the states identified by the Key of the solver. A node in
+	// the queue is replaced when a cheaper path to the same state is found, and a state that has already been
+	// visited is reopened when a cheaper path to it is found. Requires a lot of memory, since all visited states
+	// are remembered.
+	//
+	// Unlike A* with the CheapestPathConstraint, this will return the optimal solution even if the heuristic is
+	// admissible but not consistent. Also no stale duplicates are kept in the queue.
+	AstarReopening Algorithm = iota
+
+	// ExternalBreadthFirst is a breadth-first search that keeps the states on disk, for searches that don't fit in
+	// memory. Requires the ExternalMemory settings of the solver. The states of each depth are stored in a sorted
+	// file, from which the states of the previous depths are removed, so each state is visited only once. The path
+	// to a solution is reconstructed by searching the previous depths backwards, which is relatively expensive.
+	//
+	// Intended for exhaustive searches, like finding all reachable states of a problem. The Constraint and Limit
+	// of the solver are not used, MaxDepth is.
+	ExternalBreadthFirst Algorithm = iota
+
+	// PartialExpansionAstar (PEA*) is A* that only adds the children with the same value as their parent to the
+	// queue. The parent is added to the queue again with the smallest value of its remaining children, and expanded
+	// again when that value is reached. This saves a lot of memory when the branching factor is high, since most of
+	// the children are never added to the queue, at the expense of generating the children multiple times. States
+	// can implement PartialExpansionState to generate only the children that are needed. The heuristic is computed
+	// once for each child, so Expand must return the same children in the same order each time it is called.
+	//
+	// Wil return the optimal solution if the heuristic is admissible
+	PartialExpansionAstar Algorithm = iota
+
+	// FringeSearch performs iterations with an increasing limit like IDA*, but keeps the frontier between the
+	// iterations instead of starting again from the root states. The nodes that exceed the limit of an iteration
+	// are kept for the next iteration, so each node is visited only once. Uses more memory than IDA* but usually
+	// less than A*, since no priority queue is needed.
+	//
+	// Will find the optimal solution if the heuristic is admissible
+	FringeSearch Algorithm = iota
+
+	// DepthFirstBranchAndBound is a depth-first search for optimization problems. It keeps the costs of the best
+	// solution found so far, and drops all nodes with a value (costs plus heuristic) that is not lower than those
+	// costs. Each solution is therefore cheaper than the previous one, and when the search is completed the last
+	// solution is proven to be optimal. Requires very little memory, like DepthFirst.
+	//
+	// Will find the optimal solution as last solution if the heuristic is admissible
+	DepthFirstBranchAndBound Algorithm = iota
 )
 
 func (a Algorithm) String() string {
@@ -51,6 +104,18 @@ func (a Algorithm) String() string {
 		return "BreadthFirst"
 	case DepthFirst:
 		return "DepthFirst"
+	case IterativeDeepening:
+		return "IterativeDeepening"
+	case AstarReopening:
+		return "A* (reopening)"
+	case ExternalBreadthFirst:
+		return "ExternalBreadthFirst"
+	case PartialExpansionAstar:
+		return "PEA*"
+	case FringeSearch:
+		return "FringeSearch"
+	case DepthFirstBranchAndBound:
+		return "DepthFirstBranchAndBound"
 	}
 	return "<unknown>"
 }
@@ -60,43 +125,213 @@ type strategy interface {
 	Add(node *node)
 }
 
-// A* strategy, based on a priority queue
-type priorityQueue []*node
+// Can be implemented by strategies that drop the nodes that are not cheaper than a node they already have for the
+// state, so those nodes are dropped before they are created
+type duplicateDropper interface {
+	isDuplicate(state State, cost float64) bool
+}
+
+// TieBreaker is a marker interface for the policies that decide which of the nodes with an equal value is taken first
+// from the A* queue.
+type TieBreaker interface{}
+
+type tieBreaker struct {
+	name string
+	less func(a, b *node) bool // optional, returns true if a should be taken before b
+	lifo bool                  // take the last added of the remaining equal nodes first
+}
+
+func (t tieBreaker) String() string {
+	return t.name
+}
+
+// FIFO takes the nodes with equal value in the order in which they were added. This is the default.
+func FIFO() TieBreaker {
+	return tieBreaker{"FIFO", nil, false}
+}
+
+// LIFO takes the last added node first of the nodes with equal value. Since the children of the last expanded node
+// are added last, this results in depth-first behaviour on plateaus of equal value.
+func LIFO() TieBreaker {
+	return tieBreaker{"LIFO", nil, true}
+}
+
+// HighestCostFirst takes the node with the highest costs first of the nodes with equal value. Since the value is
+// the sum of the costs and the heuristic, this is equivalent to taking the node with the lowest heuristic first.
+// Typically reduces the number of visited nodes on problems with many nodes of equal value. Remaining ties are
+// broken in FIFO order.
+func HighestCostFirst() TieBreaker {
+	return tieBreaker{"HighestCostFirst", func(a, b *node) bool {
+		return a.cost > b.cost
+	}, false}
+}
+
+// DeepestFirst takes the node that is deepest in the search tree first of the nodes with equal value. Remaining
+// ties are broken in FIFO order.
+func DeepestFirst() TieBreaker {
+	return tieBreaker{"DeepestFirst", func(a, b *node) bool {
+		return a.depth > b.depth
+	}, false}
+}
 
-func (pq priorityQueue) Len() int {
-	return len(pq)
+// CustomTieBreaker takes the states in the order defined by the provided function, which should return true if
+// a should be taken before b. Remaining ties are broken in FIFO order.
+func CustomTieBreaker(less func(a, b State) bool) TieBreaker {
+	return tieBreaker{"CustomTieBreaker", func(a, b *node) bool {
+		return less(a.state, b.state)
+	}, false}
 }
 
-func (pq priorityQueue) Less(i, j int) bool {
-	return pq[i].value < pq[j].value
+// A* strategy, based on a priority queue. The sequence number of the entries guarantees a deterministic order.
+type pqEntry struct {
+	node *node
+	seq  int
 }
 
-func (pq priorityQueue) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+type priorityQueue struct {
+	entries []pqEntry
+	tie     tieBreaker
+	seq     int
+}
+
+func (pq *priorityQueue) Len() int {
+	return len(pq.entries)
+}
+
+// Returns true if node a with sequence number aseq should be taken before node b with sequence number bseq
+func (t tieBreaker) before(a *node, aseq int, b *node, bseq int) bool {
+	if a.value != b.value {
+		return a.value < b.value
+	}
+	if t.less != nil {
+		if t.less(a, b) {
+			return true
+		}
+		if t.less(b, a) {
+			return false
+		}
+	}
+	if t.lifo {
+		return aseq > bseq
+	}
+	return aseq < bseq
+}
+
+func (pq *priorityQueue) Less(i, j int) bool {
+	a, b := pq.entries[i], pq.entries[j]
+	return pq.tie.before(a.node, a.seq, b.node, b.seq)
+}
+
+func (pq *priorityQueue) Swap(i, j int) {
+	pq.entries[i], pq.entries[j] = pq.entries[j], pq.entries[i]
 }
 
 func (pq *priorityQueue) Push(x interface{}) {
-	item := x.(*node)
-	*pq = append(*pq, item)
+	pq.entries = append(pq.entries, x.(pqEntry))
 }
 
 func (pq *priorityQueue) Pop() interface{} {
-	old := *pq
+	old := pq.entries
 	n := len(old)
 	item := old[n-1]
-	*pq = old[0 : n-1]
+	pq.entries = old[0 : n-1]
 	return item
 }
 
 func (pq *priorityQueue) Take() *node {
-	if len(*pq) == 0 {
+	if len(pq.entries) == 0 {
 		return nil
 	}
-	return heap.Pop(pq).(*node)
+	return heap.Pop(pq).(pqEntry).node
 }
 
 func (pq *priorityQueue) Add(node *node) {
-	heap.Push(pq, node)
+	heap.Push(pq, pqEntry{node, pq.seq})
+	pq.seq++
+}
+
+// A* strategy with duplicate detection, based on an indexed priority queue that supports decrease-key. All states
+// that have been added are remembered with the cheapest node found for them. The index of a closed state is -1.
+type reopenEntry struct {
+	node  *node
+	seq   int
+	index int
+}
+
+type reopeningQueue struct {
+	entries []*reopenEntry
+	states  map[interface{}]*reopenEntry
+	key     func(State) interface{}
+	tie     tieBreaker
+	seq     int
+}
+
+func (q *reopeningQueue) Len() int {
+	return len(q.entries)
+}
+
+func (q *reopeningQueue) Less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	return q.tie.before(a.node, a.seq, b.node, b.seq)
+}
+
+func (q *reopeningQueue) Swap(i, j int) {
+	q.entries[i], q.entries[j] = q.entries[j], q.entries[i]
+	q.entries[i].index = i
+	q.entries[j].index = j
+}
+
+func (q *reopeningQueue) Push(x interface{}) {
+	entry := x.(*reopenEntry)
+	entry.index = len(q.entries)
+	q.entries = append(q.entries, entry)
+}
+
+func (q *reopeningQueue) Pop() interface{} {
+	old := q.entries
+	n := len(old)
+	entry := old[n-1]
+	entry.index = -1
+	q.entries = old[0 : n-1]
+	return entry
+}
+
+func (q *reopeningQueue) Take() *node {
+	if len(q.entries) == 0 {
+		return nil
+	}
+	return heap.Pop(q).(*reopenEntry).node
+}
+
+// Returns true if the state is known with a path that is at most as expensive
+func (q *reopeningQueue) isDuplicate(state State, cost float64) bool {
+	entry, ok := q.states[q.key(state)]
+	return ok && cost >= entry.node.cost
+}
+
+func (q *reopeningQueue) Add(node *node) {
+	key := q.key(node.state)
+	entry, ok := q.states[key]
+	switch {
+	case !ok:
+		entry = &reopenEntry{node, q.seq, -1}
+		q.states[key] = entry
+		heap.Push(q, entry)
+	case node.cost >= entry.node.cost:
+		// not cheaper than the known path to the state
+		return
+	case entry.index >= 0:
+		// decrease key
+		entry.node = node
+		entry.seq = q.seq
+		heap.Fix(q, entry.index)
+	default:
+		// reopen
+		entry.node = node
+		entry.seq = q.seq
+		heap.Push(q, entry)
+	}
+	q.seq++
 }
 
 // Depth-first strategy, based on a lifo queue
@@ -164,19 +399,203 @@ func grow(b *ringbuffer) {
 	b.end = oldsize
 }
 
-func aStar() strategy {
-	pq := make(priorityQueue, 0, 64)
+// A* strategy for integer values, based on a bucket queue. Each bucket contains the nodes of a single value in a
+// queue that only needs to take care of the tie-breaking. The buckets cover a window of bucketWindow values, other
+// values, like infinity or values that are not integers, are kept in an overflow priority queue. When the buckets are
+// empty the window is moved to the smallest value in the overflow queue. The overflow queue never contains integer
+// values within the window, so the nodes with equal value are always in the same queue.
+const bucketWindow = 1024
+
+type bucketQueue struct {
+	buckets   []strategy // bucket i contains the nodes with value offset+i, nil if not used yet
+	sizes     []int      // the number of nodes in each bucket
+	offset    int
+	current   int // all buckets before the current bucket are empty
+	overflow  *priorityQueue
+	newBucket func() strategy
+	lifo      bool
+}
+
+// Returns the index of the bucket for the value, or -1 if the value is not within the window
+func (q *bucketQueue) index(value float64) int {
+	if value != math.Trunc(value) || value < float64(q.offset) || value >= float64(q.offset+bucketWindow) {
+		return -1
+	}
+	return int(value) - q.offset
+}
+
+func (q *bucketQueue) Take() *node {
+	for {
+		for q.current < len(q.buckets) && q.sizes[q.current] == 0 {
+			q.current++
+		}
+		if q.current < len(q.buckets) {
+			if q.overflow.Len() > 0 && q.overflow.entries[0].node.value < float64(q.offset+q.current) {
+				return q.overflow.Take()
+			}
+			q.sizes[q.current]--
+			return q.buckets[q.current].Take()
+		}
+		if q.overflow.Len() == 0 {
+			return nil
+		}
+		value := q.overflow.entries[0].node.value
+		if value != math.Trunc(value) || math.Abs(value) >= 1<<52 {
+			return q.overflow.Take()
+		}
+		q.move(int(value))
+	}
+}
+
+// Moves the window of the buckets, which are all empty, to start at the offset, and moves the nodes in the new
+// window from the overflow queue to the buckets
+func (q *bucketQueue) move(offset int) {
+	q.offset = offset
+	q.current = 0
+	var moved, remaining []*node
+	for q.overflow.Len() > 0 && q.overflow.entries[0].node.value < float64(offset+bucketWindow) {
+		n := q.overflow.Take()
+		if q.index(n.value) < 0 {
+			remaining = append(remaining, n)
+		} else {
+			moved = append(moved, n)
+		}
+	}
+	for _, n := range remaining {
+		q.overflow.Add(n)
+	}
+	if q.lifo {
+		// the nodes are taken from the overflow queue with the last added node first
+		for i, j := 0, len(moved)-1; i < j; i, j = i+1, j-1 {
+			moved[i], moved[j] = moved[j], moved[i]
+		}
+	}
+	for _, n := range moved {
+		q.addToBucket(q.index(n.value), n)
+	}
+}
+
+func (q *bucketQueue) addToBucket(i int, node *node) {
+	for i >= len(q.buckets) {
+		q.buckets = append(q.buckets, nil)
+		q.sizes = append(q.sizes, 0)
+	}
+	if q.buckets[i] == nil {
+		q.buckets[i] = q.newBucket()
+	}
+	q.buckets[i].Add(node)
+	q.sizes[i]++
+	if i < q.current {
+		q.current = i
+	}
+}
+
+func (q *bucketQueue) Add(node *node) {
+	if i := q.index(node.value); i >= 0 {
+		q.addToBucket(i, node)
+	} else {
+		q.overflow.Add(node)
+	}
+}
+
+func aStar(tie tieBreaker) strategy {
+	pq := priorityQueue{make([]pqEntry, 0, 64), tie, 0}
 	heap.Init(&pq)
 	return &pq
 }
 
+func aStarReopening(key func(State) interface{}, canonical func(State) State, tie tieBreaker) strategy {
+	if key == nil {
+		panic("A* with reopening requires a key function, see Solver.Key")
+	}
+	if canonical != nil {
+		stateKey := key
+		key = func(state State) interface{} {
+			return stateKey(canonical(state))
+		}
+	}
+	return &reopeningQueue{make([]*reopenEntry, 0, 64), make(map[interface{}]*reopenEntry), key, tie, 0}
+}
+
 func depthFirst() strategy {
 	queue := make(lifo, 0, 64)
 	return &queue
 }
 
+func integerAStar(tie tieBreaker) strategy {
+	newBucket := func() strategy {
+		return aStar(tie)
+	}
+	if tie.less == nil {
+		// ties are broken on insertion order only, which doesn't need a priority queue
+		if tie.lifo {
+			newBucket = depthFirst
+		} else {
+			newBucket = breadthFirst
+		}
+	}
+	return &bucketQueue{overflow: aStar(tie).(*priorityQueue), newBucket: newBucket, lifo: tie.lifo}
+}
+
 func breadthFirst() strategy {
 	var b ringbuffer
 	b.buffer = make([]*node, 64)
 	return &b
 }
+
+// Queue for fringe search. The nodes are taken depth-first from the now list as long as their value is within the
+// bound of the current iteration, the other nodes are moved to the later list. When the now list is empty the later
+// list becomes the now list for the next iteration, with the smallest value of its nodes as bound.
+type fringe struct {
+	now   lifo
+	later []*node
+	bound float64
+	min   float64 // the smallest value of the nodes in the later list
+}
+
+func (f *fringe) Take() *node {
+	for {
+		if len(f.now) == 0 {
+			if len(f.later) == 0 {
+				return nil
+			}
+			// the nodes are taken in the order in which they were moved to the later list
+			f.now, f.later = f.later, f.now[:0]
+			for i, j := 0, len(f.now)-1; i < j; i, j = i+1, j-1 {
+				f.now[i], f.now[j] = f.now[j], f.now[i]
+			}
+			f.bound = f.min
+			f.min = math.Inf(1)
+		}
+		n := f.now.Take()
+		if n.value <= f.bound {
+			return n
+		}
+		f.later = append(f.later, n)
+		f.min = math.Min(f.min, n.value)
+	}
+}
+
+func (f *fringe) Add(node *node) {
+	f.now.Add(node)
+}
+
+// The bound of the first iteration is the smallest value of the root states
+func fringeQueue() strategy {
+	return &fringe{nil, nil, math.Inf(-1), math.Inf(1)}
+}
+
+// Depth-first queue that drops the nodes that exceed the limit, which is lowered during the search by branch and bound
+type boundedDepthFirst struct {
+	lifo
+	limit *float64
+}
+
+func (q *boundedDepthFirst) Take() *node {
+	for {
+		n := q.lifo.Take()
+		if n == nil || n.value <= *q.limit {
+			return n
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package solve
+
+// A loopless path to a goal, found by the k-shortest-paths search
+type simplePath struct {
+	nodes []*node // from the root to the goal
+	keys  []interface{}
+}
+
+func newSimplePath(goal *node, key func(State) interface{}) simplePath {
+	var nodes []*node
+	for n := goal; n != nil; n = n.parent {
+		nodes = append(nodes, n)
+	}
+	p := simplePath{make([]*node, len(nodes)), make([]interface{}, len(nodes))}
+	for i, n := range nodes {
+		p.nodes[len(nodes)-1-i] = n
+		p.keys[len(nodes)-1-i] = key(n.state)
+	}
+	return p
+}
+
+func (p simplePath) cost() float64 {
+	return p.nodes[len(p.nodes)-1].cost
+}
+
+// Returns true if the first n states of the paths are equal
+func (p simplePath) samePrefix(other simplePath, n int) bool {
+	if len(p.keys) < n || len(other.keys) < n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if p.keys[i] != other.keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p simplePath) equals(other simplePath) bool {
+	return len(p.keys) == len(other.keys) && p.samePrefix(other, len(p.keys))
+}
+
+// Number of states in the path that are not in the other path
+func (p simplePath) difference(other simplePath) int {
+	states := make(map[interface{}]bool, len(other.keys))
+	for _, k := range other.keys {
+		states[k] = true
+	}
+	count := 0
+	for _, k := range p.keys {
+		if !states[k] {
+			count++
+		}
+	}
+	return count
+}
+
+// Constraint of a spur search. Drops the states of the path up to the spur node, which are removed from the graph,
+// and the banned children of the spur node. Like the CheapestPathConstraint it also drops the states that are
+// reached before with lower or equal costs, so each state is expanded at most once.
+type spurConstraint struct {
+	key     func(State) interface{}
+	spur    *node
+	removed map[interface{}]bool
+	banned  map[interface{}]bool
+	best    map[interface{}]float64
+}
+
+func (c spurConstraint) onExpand(node *node) bool {
+	k := c.key(node.state)
+	if c.removed[k] || node.parent == c.spur && c.banned[k] {
+		return true
+	}
+	if cost, ok := c.best[k]; ok && cost <= node.cost {
+		return true
+	}
+	c.best[k] = node.cost
+	return false
+}
+
+func (c spurConstraint) onVisit(node *node) bool {
+	return c.best[c.key(node.state)] < node.cost
+}
+
+func (c spurConstraint) reset() {}
+
+// Yen's algorithm. Each path that is found is used to find new candidates, by deviating from it at each of its
+// states while avoiding the paths that are found before with the same prefix.
+func kShortestPaths(ss *solver, k int, diversity int) []Result {
+	if ss.key == nil {
+		panic("KShortestPaths requires the Key of the solver")
+	}
+	key := func(state State) interface{} {
+		return ss.key(canonicalState(ss.canonical, state))
+	}
+	context := ss.newContext()
+	visited, expanded := 0, 0
+	search := func(start []*node, spur *node, removed map[interface{}]bool, banned map[interface{}]bool) (simplePath, bool) {
+		c := spurConstraint{key, spur, removed, banned, make(map[interface{}]float64)}
+		for _, n := range start {
+			k := key(n.state)
+			if cost, ok := c.best[k]; !ok || n.cost < cost {
+				c.best[k] = n.cost
+			}
+		}
+		s := newSearch(ss.aStarQueue(), c, nil, context, ss.limit, ss.maxDepth)
+		for _, n := range start {
+			s.queue.Add(n)
+		}
+		r := s.run()
+		visited += r.visited
+		expanded += r.expanded
+		if r.node == nil {
+			return simplePath{}, false
+		}
+		return newSimplePath(r.node, key), true
+	}
+
+	var found []simplePath // the paths taken from the candidates, including those that are not diverse enough
+	var accepted []simplePath
+	var candidates []simplePath // ordered by costs
+	addCandidate := func(p simplePath) {
+		for _, other := range found {
+			if p.equals(other) {
+				return
+			}
+		}
+		for _, other := range candidates {
+			if p.equals(other) {
+				return
+			}
+		}
+		i := len(candidates)
+		for i > 0 && candidates[i-1].cost() > p.cost() {
+			i--
+		}
+		candidates = append(candidates, simplePath{})
+		copy(candidates[i+1:], candidates[i:])
+		candidates[i] = p
+	}
+	isDiverse := func(p simplePath) bool {
+		for _, other := range accepted {
+			if p.difference(other) < diversity {
+				return false
+			}
+		}
+		return true
+	}
+
+	var roots []*node
+	for _, rootState := range ss.rootStates {
+		roots = append(roots, rootNode(rootState, context))
+	}
+	if p, ok := search(roots, nil, nil, nil); ok {
+		addCandidate(p)
+	}
+	var results []Result
+	for len(results) < k && len(candidates) > 0 {
+		p := candidates[0]
+		candidates = candidates[1:]
+		found = append(found, p)
+		if isDiverse(p) {
+			accepted = append(accepted, p)
+			goal := p.nodes[len(p.nodes)-1]
+			results = append(results, ss.toResult(&result{node: goal, visited: visited, expanded: expanded}))
+			if len(results) == k {
+				break
+			}
+		}
+		// deviate from the path after its first i+1 states, where i == -1 deviates at the root states
+		for i := -1; i < len(p.nodes)-1; i++ {
+			banned := make(map[interface{}]bool)
+			for _, other := range found {
+				if len(other.keys) > i+1 && other.samePrefix(p, i+1) {
+					banned[other.keys[i+1]] = true
+				}
+			}
+			var start []*node
+			var spur *node
+			// the path up to the spur node is removed from the graph, so the spur path doesn't contain loops
+			removed := make(map[interface{}]bool)
+			for j := 0; j <= i; j++ {
+				removed[p.keys[j]] = true
+			}
+			if i < 0 {
+				for _, root := range roots {
+					if !banned[key(root.state)] {
+						start = append(start, root)
+					}
+				}
+			} else {
+				spur = p.nodes[i]
+				start = []*node{spur}
+			}
+			if q, ok := search(start, spur, removed, banned); ok {
+				addCandidate(q)
+			}
+		}
+	}
+	return results
+}